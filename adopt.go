@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// needsAdoption reports whether dir exists and holds files, but is not a
+// checkout goget would skip: git clone would refuse to clone into it
+func needsAdoption(dir string) bool {
+	if existingCheckout(dir) != "" || hasMovedNote(dir) {
+		return false
+	}
+	entries, err := os.ReadDir(dir)
+	return err == nil && len(entries) > 0
+}
+
+// adoptDirectory turns the existing non-git directory dir into a clone of
+// repoURL. If the files in dir match a fetched commit, that commit is checked
+// out (the default branch if it is the tip); otherwise the default branch is
+// checked out with the files in dir left as local modifications. It returns a
+// description of the outcome for the summary. On failure, the .git directory
+// it created is removed, leaving dir as it was.
+func adoptDirectory(ctx context.Context, dir, repoURL string, opts Options) (summary string, err error) {
+	gitDir := filepath.Join(dir, ".git")
+	defer func() {
+		if err != nil {
+			os.RemoveAll(gitDir)
+		}
+	}()
+
+	// git's insteadOf rules decide which host it really connects to
+	env := remoteEnv(ctx, nil, dir, rewriteURL(opts.GitURLRewrites, repoURL, false), opts)
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", repoURL},
+		{"fetch", "--quiet", "origin"},
+		{"remote", "set-head", "origin", "--auto"},
+	} {
+		if _, err := gitOutputEnv(ctx, dir, env, args...); err != nil {
+			return "", err
+		}
+	}
+
+	remoteHead, err := gitOutput(ctx, dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	if err != nil {
+		return "", err
+	}
+	branch := strings.TrimPrefix(remoteHead, "origin/")
+
+	// Stage everything to find the tree the directory holds
+	if _, err := gitOutput(ctx, dir, "add", "--all"); err != nil {
+		return "", err
+	}
+	tree, err := gitOutput(ctx, dir, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	commits, err := gitOutput(ctx, dir, "log", "--remotes", "--tags", "--format=%H %T")
+	if err != nil {
+		return "", err
+	}
+	match := matchingCommit(commits, tree)
+
+	tip, err := gitOutput(ctx, dir, "rev-parse", remoteHead)
+	if err != nil {
+		return "", err
+	}
+	if match != "" && match != tip {
+		// An older revision; check it out detached. The work tree already
+		// holds its files, so only HEAD and the index change.
+		for _, args := range [][]string{
+			{"update-ref", "--no-deref", "HEAD", match},
+			{"reset", "--quiet"},
+		} {
+			if _, err := gitOutput(ctx, dir, args...); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("matches commit %s (HEAD detached)", match[:12]), nil
+	}
+
+	for _, args := range [][]string{
+		{"symbolic-ref", "HEAD", "refs/heads/" + branch},
+		{"reset", "--quiet", remoteHead},
+		{"branch", "--quiet", "--set-upstream-to=" + remoteHead},
+	} {
+		if _, err := gitOutput(ctx, dir, args...); err != nil {
+			return "", err
+		}
+	}
+	if match != "" {
+		return fmt.Sprintf("matches %s; checked out %s", remoteHead, branch), nil
+	}
+
+	stat, err := gitOutput(ctx, dir, "diff", "--shortstat")
+	if err != nil {
+		return "", err
+	}
+	untracked, err := gitOutput(ctx, dir, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("no matching commit; local modifications on top of %s:%s", branch, adoptDiffstat(stat, untracked)), nil
+}
+
+// matchingCommit returns the first commit in the output of
+// "git log --format=%H %T" whose tree is tree, or ""
+func matchingCommit(log, tree string) string {
+	for line := range strings.SplitSeq(log, "\n") {
+		commit, commitTree, ok := strings.Cut(line, " ")
+		if ok && commitTree == tree {
+			return commit
+		}
+	}
+	return ""
+}
+
+// adoptDiffstat formats the output of "git diff --shortstat" and
+// "git ls-files --others" for the summary
+func adoptDiffstat(shortstat, untracked string) string {
+	var parts []string
+	if shortstat != "" {
+		parts = append(parts, shortstat)
+	}
+	if untracked != "" {
+		parts = append(parts, fmt.Sprintf("%d untracked files", strings.Count(untracked, "\n")+1))
+	}
+	if len(parts) == 0 {
+		return " no changes"
+	}
+	return " " + strings.Join(parts, ", ")
+}
+
+// printAdoptSummary lists the directories adopted with --adopt and how each
+// one relates to the fetched history
+func printAdoptSummary(results []DependencyResult) {
+	var adopted []DependencyResult
+	for _, result := range results {
+		if result.Adopted != "" {
+			adopted = append(adopted, result)
+		}
+	}
+	if len(adopted) == 0 {
+		return
+	}
+	fmt.Println("\nAdopted directories:")
+	for _, result := range adopted {
+		fmt.Printf("  - %s: %s\n", result.ImportPath, result.Adopted)
+	}
+}
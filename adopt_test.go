@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAdoptDirectory(t *testing.T) {
+	ctx := context.Background()
+	upstream := filepath.Join(t.TempDir(), "upstream")
+	initTestRepo(t, upstream)
+	first, err := gitOutput(ctx, upstream, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, upstream, "extra.go", "package repo\n\nconst X = 1\n")
+
+	tests := []struct {
+		name          string
+		files         map[string]string
+		expectSummary string
+		expectHead    string // "" means the tip of main
+		expectBranch  bool
+	}{
+		{
+			name:          "matches tip",
+			files:         map[string]string{"doc.go": "package repo\n", "extra.go": "package repo\n\nconst X = 1\n"},
+			expectSummary: "matches origin/main",
+			expectBranch:  true,
+		},
+		{
+			name:          "matches older commit",
+			files:         map[string]string{"doc.go": "package repo\n"},
+			expectSummary: "matches commit " + first[:12],
+			expectHead:    first,
+		},
+		{
+			name:          "local modifications",
+			files:         map[string]string{"doc.go": "package repo // changed\n", "extra.go": "package repo\n\nconst X = 1\n", "new.go": "package repo\n"},
+			expectSummary: "local modifications on top of main: 1 file changed, 1 insertion(+), 1 deletion(-), 1 untracked files",
+			expectBranch:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "repo")
+			writeFiles(t, dir, tt.files)
+			if !needsAdoption(dir) {
+				t.Fatal("needsAdoption() = false, want true")
+			}
+
+			summary, err := adoptDirectory(ctx, dir, upstream, Options{})
+			if err != nil {
+				t.Fatalf("adoptDirectory() error = %v", err)
+			}
+			if !strings.Contains(summary, tt.expectSummary) {
+				t.Errorf("summary = %q, want it to contain %q", summary, tt.expectSummary)
+			}
+
+			head, err := gitOutput(ctx, dir, "rev-parse", "HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectHead := tt.expectHead
+			if expectHead == "" {
+				expectHead, _ = gitOutput(ctx, upstream, "rev-parse", "HEAD")
+			}
+			if head != expectHead {
+				t.Errorf("HEAD = %s, want %s", head, expectHead)
+			}
+			_, err = gitOutput(ctx, dir, "symbolic-ref", "--quiet", "HEAD")
+			if onBranch := err == nil; onBranch != tt.expectBranch {
+				t.Errorf("on branch = %v, want %v", onBranch, tt.expectBranch)
+			}
+			for name, contents := range tt.files {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil || string(got) != contents {
+					t.Errorf("%s was changed by adoption", name)
+				}
+			}
+		})
+	}
+}
+
+func TestAdoptDirectoryFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "repo")
+	writeFiles(t, dir, map[string]string{"doc.go": "package repo\n"})
+
+	_, err := adoptDirectory(context.Background(), dir, filepath.Join(t.TempDir(), "missing"), Options{})
+	if err == nil {
+		t.Fatal("adoptDirectory() error = nil, want an error")
+	}
+	if !needsAdoption(dir) {
+		t.Error("failed adoption left a .git directory behind")
+	}
+}
+
+func TestNeedsAdoption(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, filepath.Join(root, "plain"), map[string]string{"doc.go": "package plain\n"})
+	writeFiles(t, filepath.Join(root, "module"), map[string]string{"go.mod": "module example.com/module\n"})
+	writeFiles(t, filepath.Join(root, "moved"), map[string]string{movedNoteName: "moved\n"})
+	if err := os.MkdirAll(filepath.Join(root, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"plain", true},
+		{"module", false},
+		{"moved", false},
+		{"empty", false},
+		{"missing", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsAdoption(filepath.Join(root, tt.name)); got != tt.expected {
+				t.Errorf("needsAdoption() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tempDirPrefix returns the prefix of the temporary directories goget clones
+// into before moving a clone to targetPath. prepareTempDir follows it with
+// the PID of the goget process that owns the directory, so a later run can
+// tell whether a leftover directory is still in use.
+func tempDirPrefix(targetPath string) string {
+	return "." + filepath.Base(targetPath) + ".goget-tmp-"
+}
+
+// prepareTempDir removes temporary directories left next to targetPath by
+// goget runs that were killed, then creates a new one for this clone.
+func prepareTempDir(targetPath string) (string, error) {
+	parent := filepath.Dir(targetPath)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", err
+	}
+	cleanStaleTempDirs(targetPath)
+	prefix := fmt.Sprintf("%s%d-", tempDirPrefix(targetPath), os.Getpid())
+	return os.MkdirTemp(parent, prefix)
+}
+
+// cleanStaleTempDirs removes temporary clone directories for targetPath
+// whose goget process is no longer running
+func cleanStaleTempDirs(targetPath string) {
+	prefix := tempDirPrefix(targetPath)
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(targetPath), globEscape(prefix)+"*"))
+	if err != nil {
+		return
+	}
+	for _, match := range matches {
+		rest := strings.TrimPrefix(filepath.Base(match), prefix)
+		pidStr, _, _ := strings.Cut(rest, "-")
+		if pid, err := strconv.Atoi(pidStr); err == nil && (pid == os.Getpid() || processAlive(pid)) {
+			// Still in use, possibly by a concurrent clone in this run
+			continue
+		}
+		fmt.Printf("Removing leftover temporary clone %s\n", match)
+		if err := os.RemoveAll(match); err != nil {
+			fmt.Fprintf(os.Stderr, "WARN: could not remove %s: %v\n", match, err)
+		}
+	}
+}
+
+// globEscape escapes the characters filepath.Match treats specially
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// verifyClone checks that dir holds a usable clone before it is moved into
+// place: a git work tree whose HEAD resolves, unless the repository is empty.
+func verifyClone(ctx context.Context, dir string) error {
+	if _, err := gitOutput(ctx, dir, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return fmt.Errorf("clone in %s is not a git work tree: %w", dir, err)
+	}
+	if _, err := gitOutput(ctx, dir, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		refs, refErr := gitOutput(ctx, dir, "for-each-ref", "--count=1")
+		if refErr != nil || refs != "" {
+			return fmt.Errorf("clone in %s has no valid HEAD: %w", dir, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanStaleTempDirs(t *testing.T) {
+	parent := t.TempDir()
+	target := filepath.Join(parent, "repo")
+
+	// PIDs this large are never assigned, so the owner is gone
+	stale := filepath.Join(parent, ".repo.goget-tmp-2147483646-123")
+	own := filepath.Join(parent, fmt.Sprintf(".repo.goget-tmp-%d-456", os.Getpid()))
+	other := filepath.Join(parent, ".other.goget-tmp-2147483646-789")
+	for _, dir := range []string{stale, own, other} {
+		if err := os.MkdirAll(filepath.Join(dir, ".git"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cleanStaleTempDirs(target)
+
+	tests := []struct {
+		name   string
+		dir    string
+		exists bool
+	}{
+		{"dead owner", stale, false},
+		{"this process", own, true},
+		{"different target", other, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := os.Stat(tt.dir)
+			if exists := err == nil; exists != tt.exists {
+				t.Errorf("exists = %v, want %v", exists, tt.exists)
+			}
+		})
+	}
+}
+
+func TestExecuteGitCommandAtomic(t *testing.T) {
+	upstream := filepath.Join(t.TempDir(), "upstream")
+	initTestRepo(t, upstream)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		url         string
+		expectError bool
+	}{
+		{"successful clone", upstream, false},
+		{"failed clone", filepath.Join(t.TempDir(), "missing"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			target := filepath.Join(parent, "repo")
+			cmd := &GitCommand{
+				URL:        tt.url,
+				TargetPath: target,
+				Args:       []string{"clone", "--quiet", tt.url, target},
+			}
+			_, err := executeGitCommand(ctx, cmd, Options{})
+			if (err != nil) != tt.expectError {
+				t.Fatalf("executeGitCommand() error = %v, expectError %v", err, tt.expectError)
+			}
+
+			_, statErr := os.Stat(filepath.Join(target, "doc.go"))
+			if cloned := statErr == nil; cloned == tt.expectError {
+				t.Errorf("clone present = %v, want %v", cloned, !tt.expectError)
+			}
+			if _, err := os.Stat(target); tt.expectError && err == nil {
+				t.Errorf("failed clone left %s behind", target)
+			}
+			leftovers, _ := filepath.Glob(filepath.Join(parent, ".repo.goget-tmp-*"))
+			if len(leftovers) != 0 {
+				t.Errorf("temporary directories left behind: %v", leftovers)
+			}
+		})
+	}
+}
+
+func TestExecuteGitCommandCleansBesideExistingClone(t *testing.T) {
+	parent := t.TempDir()
+	target := filepath.Join(parent, "repo")
+	stale := filepath.Join(parent, ".repo.goget-tmp-2147483646-123")
+	for _, dir := range []string{filepath.Join(target, ".git"), filepath.Join(stale, ".git")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	skipped, err := executeGitCommand(context.Background(), &GitCommand{TargetPath: target}, Options{})
+	if err != nil || !skipped {
+		t.Fatalf("executeGitCommand() = %v, %v, want the existing clone skipped", skipped, err)
+	}
+	if _, err := os.Stat(stale); err == nil {
+		t.Errorf("leftover temporary clone %s not removed", stale)
+	}
+}
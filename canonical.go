@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/build"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// movedNoteName is the file left behind in a directory whose clone was
+// relocated to its canonical import path with --canonical.
+const movedNoteName = "GOGET_MOVED.txt"
+
+// hasMovedNote reports whether dir holds the note left behind when its clone
+// was relocated with --canonical
+func hasMovedNote(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, movedNoteName))
+	return err == nil
+}
+
+// declaredImportPath returns the import path a repository declares for
+// itself: the module path from go.mod, or failing that the import comment
+// (package foo // import "example.com/foo") on the root package. It returns
+// "" if the repository declares neither.
+func declaredImportPath(dir string) (string, error) {
+	modPath, err := readModulePath(filepath.Join(dir, "go.mod"))
+	if err == nil {
+		return modPath, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	pkg, err := build.Default.ImportDir(dir, build.ImportComment)
+	if err != nil {
+		// No buildable Go files in the root; nothing to check
+		return "", nil
+	}
+	return pkg.ImportComment, nil
+}
+
+// readModulePath returns the path on the module line of a go.mod file
+func readModulePath(modPath string) (string, error) {
+	file, err := os.Open(modPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		after, ok := strings.CutPrefix(line, "module")
+		if !ok || (after != "" && after[0] != ' ' && after[0] != '\t') {
+			continue
+		}
+		if idx := strings.Index(after, "//"); idx != -1 {
+			after = after[:idx]
+		}
+		after = strings.TrimSpace(after)
+		if unquoted, err := strconv.Unquote(after); err == nil {
+			after = unquoted
+		}
+		return after, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading %s: %w", modPath, err)
+	}
+	return "", nil
+}
+
+// stripMajorVersion removes a /vN major version suffix (N >= 2) from a
+// module path. In GOPATH mode, github.com/foo/bar/v2 lives at
+// github.com/foo/bar.
+func stripMajorVersion(modPath string) string {
+	idx := strings.LastIndex(modPath, "/v")
+	if idx == -1 {
+		return modPath
+	}
+	n, err := strconv.Atoi(modPath[idx+2:])
+	if err != nil || n < 2 {
+		return modPath
+	}
+	return modPath[:idx]
+}
+
+// checkCanonicalPath compares the import path a fresh clone declares for
+// itself with the path it was cloned under. On a mismatch it logs a warning,
+// or, if relocate is true, moves the clone to the declared path and leaves a
+// note in the old location. It returns the directory the clone ends up in.
+func checkCanonicalPath(gopath, targetPath string, relocate bool) (string, error) {
+	declared, err := declaredImportPath(targetPath)
+	if err != nil || declared == "" {
+		return targetPath, err
+	}
+
+	srcDir := filepath.Join(gopath, "src")
+	absTarget, err := filepath.Abs(targetPath)
+	if err != nil {
+		return targetPath, err
+	}
+	rel, err := filepath.Rel(srcDir, absTarget)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		// Not inside GOPATH, so there is no canonical location to compare
+		return targetPath, nil
+	}
+	clonedAs := filepath.ToSlash(rel)
+
+	canonical := stripMajorVersion(declared)
+	if declared == clonedAs || canonical == clonedAs {
+		return targetPath, nil
+	}
+
+	if !relocate {
+		log.Printf("WARN: %s declares import path %q but was cloned to %s; GOPATH builds may fail (use --canonical to move it)", clonedAs, declared, absTarget)
+		return targetPath, nil
+	}
+
+	newPath := filepath.Join(srcDir, filepath.FromSlash(canonical))
+	if _, err := os.Stat(newPath); err == nil {
+		return targetPath, fmt.Errorf("%s declares import path %q, but %s already exists", clonedAs, declared, newPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return targetPath, err
+	}
+	if err := os.Rename(absTarget, newPath); err != nil {
+		return targetPath, fmt.Errorf("could not move clone to canonical path: %w", err)
+	}
+
+	note := fmt.Sprintf("goget moved this repository to %s because it declares import path %q.\n", newPath, declared)
+	if err := os.MkdirAll(absTarget, 0755); err != nil {
+		return newPath, err
+	}
+	if err := os.WriteFile(filepath.Join(absTarget, movedNoteName), []byte(note), 0644); err != nil {
+		return newPath, err
+	}
+	fmt.Printf("Moved %s to canonical path %s\n", absTarget, newPath)
+	return newPath, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStripMajorVersion(t *testing.T) {
+	tests := []struct {
+		modPath  string
+		expected string
+	}{
+		{"github.com/foo/bar", "github.com/foo/bar"},
+		{"github.com/foo/bar/v2", "github.com/foo/bar"},
+		{"github.com/foo/bar/v10", "github.com/foo/bar"},
+		{"github.com/foo/bar/v1", "github.com/foo/bar/v1"},
+		{"github.com/foo/vendor", "github.com/foo/vendor"},
+		{"gopkg.in/yaml.v3", "gopkg.in/yaml.v3"},
+	}
+
+	for _, tt := range tests {
+		if got := stripMajorVersion(tt.modPath); got != tt.expected {
+			t.Errorf("stripMajorVersion(%q) = %q, want %q", tt.modPath, got, tt.expected)
+		}
+	}
+}
+
+func TestDeclaredImportPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected string
+	}{
+		{
+			name:     "go.mod module line",
+			files:    map[string]string{"go.mod": "module go.foo.dev/bar\n\ngo 1.21\n"},
+			expected: "go.foo.dev/bar",
+		},
+		{
+			name:     "quoted module path with comment",
+			files:    map[string]string{"go.mod": "// comment\nmodule \"go.foo.dev/bar\" // trailing\n"},
+			expected: "go.foo.dev/bar",
+		},
+		{
+			name:     "import comment",
+			files:    map[string]string{"bar.go": "package bar // import \"go.foo.dev/bar\"\n"},
+			expected: "go.foo.dev/bar",
+		},
+		{
+			name:     "nothing declared",
+			files:    map[string]string{"README": "hello\n"},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, contents := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := declaredImportPath(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("declaredImportPath() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckCanonicalPath(t *testing.T) {
+	tests := []struct {
+		name         string
+		clonedAs     string
+		module       string
+		relocate     bool
+		expectedPath string
+	}{
+		{
+			name:         "module path matches",
+			clonedAs:     "github.com/foo/bar",
+			module:       "github.com/foo/bar",
+			expectedPath: "github.com/foo/bar",
+		},
+		{
+			name:         "major version suffix matches",
+			clonedAs:     "github.com/foo/bar",
+			module:       "github.com/foo/bar/v3",
+			expectedPath: "github.com/foo/bar",
+		},
+		{
+			name:         "mismatch only warns",
+			clonedAs:     "github.com/foo/bar",
+			module:       "go.foo.dev/bar",
+			expectedPath: "github.com/foo/bar",
+		},
+		{
+			name:         "mismatch relocates",
+			clonedAs:     "github.com/foo/bar",
+			module:       "go.foo.dev/bar",
+			relocate:     true,
+			expectedPath: "go.foo.dev/bar",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gopath := t.TempDir()
+			target := filepath.Join(gopath, "src", filepath.FromSlash(tt.clonedAs))
+			if err := os.MkdirAll(target, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(target, "go.mod"), []byte("module "+tt.module+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := checkCanonicalPath(gopath, target, tt.relocate)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := filepath.Join(gopath, "src", filepath.FromSlash(tt.expectedPath))
+			if got != want {
+				t.Errorf("checkCanonicalPath() = %q, want %q", got, want)
+			}
+
+			if _, err := os.Stat(filepath.Join(got, "go.mod")); err != nil {
+				t.Errorf("go.mod missing from %s: %v", got, err)
+			}
+			if got != target {
+				note, err := os.ReadFile(filepath.Join(target, movedNoteName))
+				if err != nil {
+					t.Fatalf("expected a note in the old location: %v", err)
+				}
+				if !strings.Contains(string(note), got) {
+					t.Errorf("note %q does not mention new location %q", note, got)
+				}
+			}
+		})
+	}
+}
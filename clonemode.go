@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Filters accepted by --filter
+const (
+	filterBlobless = "blob:none"
+	filterTreeless = "tree:0"
+)
+
+// CloneMode selects a shallow, partial or sparse clone. The zero value is a
+// full clone.
+type CloneMode struct {
+	Depth  int    // history depth; 0 means all of it
+	Filter string // partial clone filter, filterBlobless or filterTreeless
+	Sparse bool   // check out only the top-level files and SparsePaths
+
+	SparsePaths []string // directories to populate with a cone sparse checkout
+}
+
+// cloneMode returns the mode for cloning the repository at targetPath for
+// the package in config, given the command line flags
+func cloneMode(opts Options, config *Config, targetPath string) CloneMode {
+	mode := CloneMode{Depth: opts.Depth, Filter: opts.Filter, Sparse: opts.Sparse}
+	if !opts.Sparse || strings.HasPrefix(config.ImportPath, ".") {
+		mode.Sparse = false
+		return mode
+	}
+	pkgDir := filepath.Join(config.GOPATH, "src", config.ImportPath)
+	if subdir, err := filepath.Rel(targetPath, pkgDir); err == nil && subdir != "." && !strings.HasPrefix(subdir, "..") {
+		mode.SparsePaths = []string{filepath.ToSlash(subdir)}
+	}
+	return mode
+}
+
+// IsFull reports whether the mode is a plain full clone
+func (m CloneMode) IsFull() bool {
+	return m.Depth == 0 && m.Filter == "" && !m.Sparse
+}
+
+// String describes the mode for the summary, e.g. "depth=1, filter=blob:none"
+func (m CloneMode) String() string {
+	if m.IsFull() {
+		return "full"
+	}
+	var parts []string
+	if m.Depth > 0 {
+		parts = append(parts, "depth="+strconv.Itoa(m.Depth))
+	}
+	if m.Filter != "" {
+		parts = append(parts, "filter="+m.Filter)
+	}
+	if m.Sparse {
+		if len(m.SparsePaths) == 0 {
+			parts = append(parts, "sparse (top level only)")
+		} else {
+			parts = append(parts, "sparse ("+strings.Join(m.SparsePaths, ", ")+")")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// args returns the git clone flags for the mode
+func (m CloneMode) args() []string {
+	var args []string
+	if m.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(m.Depth))
+	}
+	if m.Filter != "" {
+		args = append(args, "--filter="+m.Filter)
+	}
+	if m.Sparse {
+		args = append(args, "--sparse")
+	}
+	return args
+}
+
+// setMode makes c clone in the given mode
+func (c *GitCommand) setMode(mode CloneMode) {
+	c.Mode = mode
+	// Right after "clone", before the URL and path
+	c.Args = slices.Insert(c.Args, 1, mode.args()...)
+}
+
+// populateSparse checks out the sparse directories of the fresh clone in dir
+func populateSparse(ctx context.Context, dir string, mode CloneMode) error {
+	if !mode.Sparse || len(mode.SparsePaths) == 0 {
+		return nil
+	}
+	args := append([]string{"sparse-checkout", "set", "--cone"}, mode.SparsePaths...)
+	if _, err := gitOutput(ctx, dir, args...); err != nil {
+		return fmt.Errorf("could not set up sparse checkout: %w", err)
+	}
+	return nil
+}
+
+// validFilter reports whether filter is one --filter accepts
+func validFilter(filter string) bool {
+	return filter == "" || filter == filterBlobless || filter == filterTreeless
+}
+
+// findRepoDir returns the checkout under $GOPATH/src holding importPath, by
+// walking up from the package directory
+func findRepoDir(gopath, importPath string) (string, error) {
+	srcDir := filepath.Join(gopath, "src")
+	dir := filepath.Join(srcDir, filepath.FromSlash(importPath))
+	for dir != srcDir && strings.HasPrefix(dir, srcDir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, nil
+		}
+		dir = filepath.Dir(dir)
+	}
+	return "", fmt.Errorf("no git checkout of %s under %s", importPath, srcDir)
+}
+
+// unshallow converts the shallow, partial or sparse clone in dir to a full
+// one. It returns the conversions it made, or nil if dir was already a full
+// clone.
+func unshallow(ctx context.Context, dir string, opts Options) ([]string, error) {
+	env := remoteEnv(ctx, nil, dir, repoRemoteURL(ctx, dir), opts)
+	var done []string
+
+	if shallow, err := gitOutput(ctx, dir, "rev-parse", "--is-shallow-repository"); err != nil {
+		return done, err
+	} else if shallow == "true" {
+		if _, err := gitOutputEnv(ctx, dir, env, "fetch", "--quiet", "--unshallow"); err != nil {
+			return done, err
+		}
+		done = append(done, "fetched full history")
+	}
+
+	remote, err := gitOutput(ctx, dir, "config", "--get-regexp", `^remote\..*\.partialclonefilter$`)
+	if err == nil && remote != "" {
+		// "remote.origin.partialclonefilter blob:none"
+		key, _, _ := strings.Cut(remote, " ")
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "remote."), ".partialclonefilter")
+		for _, args := range [][]string{
+			{"config", "--unset", key},
+			{"fetch", "--quiet", "--refetch", name},
+			{"config", "--unset", "remote." + name + ".promisor"},
+		} {
+			if _, err := gitOutputEnv(ctx, dir, env, args...); err != nil {
+				return done, err
+			}
+		}
+		done = append(done, "fetched all objects")
+	}
+
+	if sparse, _ := gitOutput(ctx, dir, "config", "--bool", "core.sparseCheckout"); sparse == "true" {
+		if _, err := gitOutput(ctx, dir, "sparse-checkout", "disable"); err != nil {
+			return done, err
+		}
+		done = append(done, "checked out all files")
+	}
+	return done, nil
+}
+
+// runUnshallow implements "goget --unshallow <path>"
+func runUnshallow(ctx context.Context, importPath, gopath string, opts Options) error {
+	if gopath == "" {
+		return fmt.Errorf("cannot unshallow without GOPATH set")
+	}
+	dir, err := findRepoDir(firstGOPATH(gopath), importPath)
+	if err != nil {
+		return err
+	}
+	done, err := unshallow(ctx, dir, opts)
+	if err != nil {
+		return fmt.Errorf("could not unshallow %s: %w", dir, err)
+	}
+	if len(done) == 0 {
+		fmt.Printf("%s is already a full clone\n", dir)
+		return nil
+	}
+	fmt.Printf("%s: %s\n", dir, strings.Join(done, ", "))
+	return nil
+}
+
+// printCloneModeSummary lists the clone mode of each repository fetched in
+// a mode other than a full clone
+func printCloneModeSummary(results []DependencyResult) {
+	var partial []DependencyResult
+	for _, result := range results {
+		if result.Mode != "" && result.Mode != "full" {
+			partial = append(partial, result)
+		}
+	}
+	if len(partial) == 0 {
+		return
+	}
+	fmt.Println("\nClone modes:")
+	for _, result := range partial {
+		fmt.Printf("  - %s: %s\n", result.ImportPath, result.Mode)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCloneMode(t *testing.T) {
+	gopath := "/home/user/go"
+	target := "/home/user/go/src/github.com/user/repo"
+
+	tests := []struct {
+		name           string
+		importPath     string
+		opts           Options
+		expectedArgs   []string
+		expectedString string
+	}{
+		{
+			name:           "full",
+			importPath:     "github.com/user/repo",
+			expectedString: "full",
+		},
+		{
+			name:           "shallow",
+			importPath:     "github.com/user/repo",
+			opts:           Options{Depth: 1},
+			expectedArgs:   []string{"--depth", "1"},
+			expectedString: "depth=1",
+		},
+		{
+			name:           "blobless",
+			importPath:     "github.com/user/repo",
+			opts:           Options{Filter: filterBlobless},
+			expectedArgs:   []string{"--filter=blob:none"},
+			expectedString: "filter=blob:none",
+		},
+		{
+			name:           "sparse subpackage",
+			importPath:     "github.com/user/repo/pkg/sub",
+			opts:           Options{Sparse: true, Depth: 5},
+			expectedArgs:   []string{"--depth", "5", "--sparse"},
+			expectedString: "depth=5, sparse (pkg/sub)",
+		},
+		{
+			name:           "sparse root",
+			importPath:     "github.com/user/repo",
+			opts:           Options{Sparse: true},
+			expectedArgs:   []string{"--sparse"},
+			expectedString: "sparse (top level only)",
+		},
+		{
+			name:           "sparse relative path is a full clone",
+			importPath:     "./repo",
+			opts:           Options{Sparse: true},
+			expectedString: "full",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode := cloneMode(tt.opts, &Config{GOPATH: gopath, ImportPath: tt.importPath}, target)
+			if args := mode.args(); !slices.Equal(args, tt.expectedArgs) {
+				t.Errorf("args() = %v, want %v", args, tt.expectedArgs)
+			}
+			if s := mode.String(); s != tt.expectedString {
+				t.Errorf("String() = %q, want %q", s, tt.expectedString)
+			}
+		})
+	}
+}
+
+func TestSetMode(t *testing.T) {
+	cmd := &GitCommand{Args: []string{"clone", "--quiet", "git@github.com:user/repo.git", "/tmp/repo"}}
+	cmd.setMode(CloneMode{Depth: 1, Filter: filterTreeless})
+	expected := []string{"clone", "--depth", "1", "--filter=tree:0", "--quiet", "git@github.com:user/repo.git", "/tmp/repo"}
+	if !slices.Equal(cmd.Args, expected) {
+		t.Errorf("Args = %v, want %v", cmd.Args, expected)
+	}
+}
+
+func TestCloneModesAndUnshallow(t *testing.T) {
+	ctx := context.Background()
+	upstream := filepath.Join(t.TempDir(), "upstream")
+	initTestRepo(t, upstream)
+	writeFiles(t, upstream, map[string]string{"pkg/sub/sub.go": "package sub\n", "other/other.go": "package other\n"})
+	if _, err := gitOutput(ctx, upstream, "add", "."); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, upstream, "extra.go", "package repo\n")
+	if _, err := gitOutput(ctx, upstream, "config", "uploadpack.allowFilter", "true"); err != nil {
+		t.Fatal(err)
+	}
+	url := "file://" + upstream
+
+	tests := []struct {
+		name         string
+		mode         CloneMode
+		expectFiles  []string
+		missingFiles []string
+		expectDone   []string
+	}{
+		{
+			name:        "shallow",
+			mode:        CloneMode{Depth: 1},
+			expectFiles: []string{"doc.go", "other/other.go"},
+			expectDone:  []string{"fetched full history"},
+		},
+		{
+			name:        "blobless",
+			mode:        CloneMode{Filter: filterBlobless},
+			expectFiles: []string{"doc.go", "other/other.go"},
+			expectDone:  []string{"fetched all objects"},
+		},
+		{
+			name:         "sparse",
+			mode:         CloneMode{Sparse: true, SparsePaths: []string{"pkg/sub"}},
+			expectFiles:  []string{"doc.go", "pkg/sub/sub.go"},
+			missingFiles: []string{"other/other.go"},
+			expectDone:   []string{"checked out all files"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := filepath.Join(t.TempDir(), "repo")
+			cmd := &GitCommand{URL: url, TargetPath: target, Args: []string{"clone", "--quiet", url, target}}
+			cmd.setMode(tt.mode)
+			if _, err := executeGitCommand(ctx, cmd, Options{}); err != nil {
+				t.Fatalf("executeGitCommand() error = %v", err)
+			}
+			for _, name := range tt.expectFiles {
+				if _, err := os.Stat(filepath.Join(target, name)); err != nil {
+					t.Errorf("%s missing from clone", name)
+				}
+			}
+			for _, name := range tt.missingFiles {
+				if _, err := os.Stat(filepath.Join(target, name)); err == nil {
+					t.Errorf("%s present in sparse clone", name)
+				}
+			}
+
+			// Keep auto gc after the refetch from outliving the test
+			if _, err := gitOutput(ctx, target, "config", "gc.autoDetach", "false"); err != nil {
+				t.Fatal(err)
+			}
+			done, err := unshallow(ctx, target, Options{})
+			if err != nil {
+				t.Fatalf("unshallow() error = %v", err)
+			}
+			if !slices.Equal(done, tt.expectDone) {
+				t.Errorf("unshallow() = %v, want %v", done, tt.expectDone)
+			}
+			for _, name := range append(tt.expectFiles, tt.missingFiles...) {
+				if _, err := os.Stat(filepath.Join(target, name)); err != nil {
+					t.Errorf("%s missing after unshallow", name)
+				}
+			}
+			if done, err := unshallow(ctx, target, Options{}); err != nil || len(done) != 0 {
+				t.Errorf("second unshallow() = %v, %v, want nothing to do", done, err)
+			}
+		})
+	}
+}
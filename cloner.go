@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Backends for --backend
+const (
+	backendExec  = "exec"
+	backendGoGit = "go-git"
+)
+
+// Cloner clones and fetches repositories. The exec backend runs the git
+// binary; the go-git backend works without one.
+type Cloner interface {
+	// Clone clones cmd.URL into dir, which exists and is empty, with the
+	// branch, remote name and mode in cmd
+	Clone(ctx context.Context, cmd *GitCommand, dir string, opts Options) error
+	// Fetch fetches all remotes of the repository in dir
+	Fetch(ctx context.Context, dir string, opts Options) error
+	// Exists reports whether dir is the top level of a git repository
+	Exists(ctx context.Context, dir string) bool
+	// ListRemote returns the default branch and tags of the repository at
+	// url, without cloning it
+	ListRemote(ctx context.Context, url string, opts Options) (RemoteInfo, error)
+}
+
+// newCloner returns the Cloner for a --backend name
+func newCloner(backend string) (Cloner, error) {
+	switch backend {
+	case backendExec:
+		return execCloner{}, nil
+	case backendGoGit:
+		return goGitCloner{}, nil
+	}
+	return nil, fmt.Errorf("unknown --backend %q: must be %q or %q", backend, backendExec, backendGoGit)
+}
+
+// cloner returns the Cloner to use, the exec backend unless another one was
+// chosen
+func (o Options) cloner() Cloner {
+	if o.Cloner == nil {
+		return execCloner{}
+	}
+	return o.Cloner
+}
+
+// gitInstalled reports whether the git binary is on PATH. The go-git
+// backend clones and fetches without it, but steps that work on an existing
+// clone still run git. Tests replace it.
+var gitInstalled = func() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// errNoGit is the error for a step that needs the git binary when it is
+// missing
+func errNoGit(step string) error {
+	return fmt.Errorf("%s needs git, which is not installed (--backend=%s only clones and fetches)", step, backendGoGit)
+}
+
+// recursesSubmodules reports whether the clone backend checks out
+// submodules itself when --submodules is given, as go-git does
+func (o Options) recursesSubmodules() bool {
+	_, ok := o.cloner().(goGitCloner)
+	return ok
+}
+
+// execCloner runs the git binary
+type execCloner struct{}
+
+// Clone runs cmd.Args, cloning into dir instead of cmd.TargetPath
+func (execCloner) Clone(ctx context.Context, cmd *GitCommand, dir string, opts Options) error {
+	args := slices.Clone(cmd.Args)
+	if len(args) > 0 && args[len(args)-1] == cmd.TargetPath {
+		args[len(args)-1] = dir
+	}
+	var resetOrigin bool
+	var err error
+	if opts.MirrorDir != "" {
+		args, resetOrigin, err = mirrorCloneArgs(ctx, args, cmd.URL, opts)
+		if err != nil {
+			return err
+		}
+	} else if seed := findModCacheRepo(opts.ModCacheVCS, cmd.URL); seed != "" {
+		// The go command already downloaded this repository; borrow its
+		// objects, and copy them so the clone survives "go clean -modcache"
+		fmt.Printf("Seeding clone from module cache %s\n", seed)
+		args = slices.Insert(args, 1, "--reference-if-able", seed, "--dissociate")
+	}
+	if opts.SkipFsck {
+		args = append([]string{"-c", "transfer.fsckObjects=false", "-c", "fetch.fsckObjects=false"}, args...)
+	}
+	gitCmd := exec.CommandContext(ctx, "git", args...)
+	gitCmd.Stdout = os.Stdout
+
+	// Fail fast instead of hanging on prompts
+	gitCmd.Env = remoteEnv(ctx, nil, "", cmd.URL, opts)
+	// With --lfs, LFS files are handled after the clone; the smudge filter
+	// would fail without a terminal, or leave pointers anyway
+	if opts.LFS != "" {
+		gitCmd.Env = append(gitCmd.Env, "GIT_LFS_SKIP_SMUDGE=1")
+	}
+
+	// Capture stderr to classify failures
+	var stderrBuf bytes.Buffer
+	gitCmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
+
+	if err := gitCmd.Run(); err != nil {
+		return newGitError(ctx, cmd.URL, cmd.Args, stderrBuf.String(), err)
+	}
+
+	if resetOrigin || cmd.OriginURL != "" {
+		if err := resetCloneOrigin(ctx, dir, cmd.originURL()); err != nil {
+			return err
+		}
+	}
+	if err := populateSparse(ctx, dir, cmd.Mode); err != nil {
+		return err
+	}
+	return verifyClone(ctx, dir)
+}
+
+// Fetch fetches each remote in turn, like "git fetch --all", with the ssh
+// settings for its URL
+func (execCloner) Fetch(ctx context.Context, dir string, opts Options) error {
+	out, err := gitOutput(ctx, dir, "remote")
+	if err != nil {
+		return err
+	}
+	for remote := range strings.FieldsSeq(out) {
+		url, err := gitOutput(ctx, dir, "remote", "get-url", remote)
+		if err != nil {
+			return err
+		}
+		env := remoteEnv(ctx, nil, dir, url, opts)
+		if _, err := gitOutputEnv(ctx, dir, env, "fetch", "--quiet", remote); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Exists asks git for the top level of the work tree containing dir
+func (execCloner) Exists(ctx context.Context, dir string) bool {
+	top, err := gitOutput(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return false
+	}
+	return sameDir(top, dir)
+}
+
+// ListRemote runs "git ls-remote" for HEAD and the tags of url, which is much
+// cheaper than a clone, and fails in the same way if the repository can't be
+// reached
+func (execCloner) ListRemote(ctx context.Context, url string, opts Options) (RemoteInfo, error) {
+	args := []string{"ls-remote", "--symref", url, "HEAD", "refs/tags/*"}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = remoteEnv(ctx, nil, "", url, opts)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return RemoteInfo{}, newGitError(ctx, url, args, stderr.String(), err)
+	}
+	return parseLsRemote(stdout.String()), nil
+}
+
+// sameDir reports whether two paths name the same directory
+func sameDir(a, b string) bool {
+	resolve := func(path string) string {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			path = real
+		}
+		return path
+	}
+	return resolve(a) == resolve(b)
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeCloner is an in-memory Cloner: it "clones" a repository by writing
+// its files and an empty .git directory, without running git
+type fakeCloner struct {
+	repos map[string]map[string]string // URL -> file name -> contents
+	// unreachable URLs fail ListRemote; other URLs are listed even if they
+	// are not in repos, and fail to clone
+	unreachable map[string]bool
+	// gitInit makes each clone an empty git repository whose remote points
+	// at the URL it was cloned from, for steps that run git on the clone
+	gitInit bool
+
+	mu      sync.Mutex
+	clones  []string // URLs, in order
+	lists   []string // URLs passed to ListRemote, in order
+	fetches []string // directories, in order
+}
+
+func (f *fakeCloner) Clone(ctx context.Context, cmd *GitCommand, dir string, opts Options) error {
+	f.mu.Lock()
+	f.clones = append(f.clones, cmd.URL)
+	f.mu.Unlock()
+
+	files, ok := f.repos[cmd.URL]
+	if !ok {
+		return fmt.Errorf("repository %s not found", cmd.URL)
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			return err
+		}
+	}
+	if !f.gitInit {
+		return os.Mkdir(filepath.Join(dir, ".git"), 0755)
+	}
+	if _, err := gitOutput(ctx, dir, "init", "--quiet"); err != nil {
+		return err
+	}
+	_, err := gitOutput(ctx, dir, "remote", "add", cmp.Or(cmd.Origin, "origin"), cmp.Or(cmd.OriginURL, cmd.URL))
+	return err
+}
+
+func (f *fakeCloner) Fetch(ctx context.Context, dir string, opts Options) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetches = append(f.fetches, dir)
+	return nil
+}
+
+func (f *fakeCloner) ListRemote(ctx context.Context, url string, opts Options) (RemoteInfo, error) {
+	f.mu.Lock()
+	f.lists = append(f.lists, url)
+	f.mu.Unlock()
+
+	if f.unreachable[url] {
+		return RemoteInfo{}, &GitError{Kind: ErrNetwork, URL: url, Err: fmt.Errorf("%s is unreachable", url)}
+	}
+	return RemoteInfo{DefaultBranch: "main"}, nil
+}
+
+func (f *fakeCloner) Exists(ctx context.Context, dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+func TestRunGoGetWithFakeCloner(t *testing.T) {
+	tests := []struct {
+		name         string
+		arg          string
+		opts         Options
+		repos        map[string]map[string]string
+		expectClones []string
+		expectFile   string
+		expectError  bool
+	}{
+		{
+			name: "SSH",
+			arg:  "github.com/user/repo",
+			repos: map[string]map[string]string{
+				"git@github.com:user/repo.git": {"go.mod": "module github.com/user/repo\n"},
+			},
+			expectClones: []string{"git@github.com:user/repo.git"},
+			expectFile:   "github.com/user/repo/go.mod",
+		},
+		{
+			name: "falls back to HTTPS",
+			arg:  "github.com/user/repo/sub",
+			repos: map[string]map[string]string{
+				"https://github.com/user/repo.git": {"sub/sub.go": "package sub\n"},
+			},
+			expectClones: []string{"git@github.com:user/repo.git", "https://github.com/user/repo.git"},
+			expectFile:   "github.com/user/repo/sub/sub.go",
+		},
+		{
+			name:         "HTTPS only",
+			arg:          "github.com/user/repo",
+			opts:         Options{HTTPS: true},
+			repos:        map[string]map[string]string{},
+			expectClones: []string{"https://github.com/user/repo.git"},
+			expectError:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gopath := t.TempDir()
+			fake := &fakeCloner{repos: tt.repos}
+			opts := tt.opts
+			opts.Cloner = fake
+
+			_, err := runGoGet(context.Background(), tt.arg, gopath, gopath, opts)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("runGoGet() error = %v, expectError %v", err, tt.expectError)
+			}
+			if !slices.Equal(fake.clones, tt.expectClones) {
+				t.Errorf("clones = %v, want %v", fake.clones, tt.expectClones)
+			}
+			if tt.expectFile != "" {
+				if _, err := os.Stat(filepath.Join(gopath, "src", filepath.FromSlash(tt.expectFile))); err != nil {
+					t.Errorf("%s not cloned: %v", tt.expectFile, err)
+				}
+			}
+			leftovers, _ := filepath.Glob(filepath.Join(gopath, "src", "github.com", "user", ".repo.goget-tmp-*"))
+			if len(leftovers) != 0 {
+				t.Errorf("temporary directories left behind: %v", leftovers)
+			}
+		})
+	}
+}
+
+func TestGoGitCloner(t *testing.T) {
+	ctx := context.Background()
+	upstream := filepath.Join(t.TempDir(), "upstream")
+	initTestRepo(t, upstream)
+	writeFiles(t, upstream, map[string]string{"pkg/sub/sub.go": "package sub\n", "other/other.go": "package other\n"})
+	if _, err := gitOutput(ctx, upstream, "add", "."); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, upstream, "extra.go", "package repo\n")
+
+	tests := []struct {
+		name         string
+		cmd          GitCommand
+		expectFiles  []string
+		missingFiles []string
+		expectRemote string
+		expectError  bool
+	}{
+		{
+			name:         "full",
+			cmd:          GitCommand{URL: upstream},
+			expectFiles:  []string{"doc.go", "pkg/sub/sub.go", "other/other.go"},
+			expectRemote: "origin",
+		},
+		{
+			name:         "fork remote name",
+			cmd:          GitCommand{URL: upstream, Origin: upstreamRemoteName},
+			expectFiles:  []string{"doc.go"},
+			expectRemote: upstreamRemoteName,
+		},
+		{
+			name:         "sparse",
+			cmd:          GitCommand{URL: upstream, Mode: CloneMode{Sparse: true, SparsePaths: []string{"pkg/sub"}}},
+			expectFiles:  []string{"pkg/sub/sub.go"},
+			missingFiles: []string{"other/other.go"},
+			expectRemote: "origin",
+		},
+		{
+			name:        "filter",
+			cmd:         GitCommand{URL: upstream, Mode: CloneMode{Filter: filterBlobless}},
+			expectError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cloner := goGitCloner{}
+			err := cloner.Clone(ctx, &tt.cmd, dir, Options{})
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Clone() error = %v, expectError %v", err, tt.expectError)
+			}
+			if tt.expectError {
+				return
+			}
+			if !cloner.Exists(ctx, dir) {
+				t.Error("Exists() = false after clone")
+			}
+			for _, name := range tt.expectFiles {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Errorf("%s missing from clone", name)
+				}
+			}
+			for _, name := range tt.missingFiles {
+				if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+					t.Errorf("%s present in sparse clone", name)
+				}
+			}
+			if _, err := gitOutput(ctx, dir, "remote", "get-url", tt.expectRemote); err != nil {
+				t.Errorf("remote %s missing: %v", tt.expectRemote, err)
+			}
+			if err := cloner.Fetch(ctx, dir, Options{}); err != nil {
+				t.Errorf("Fetch() error = %v", err)
+			}
+		})
+	}
+
+	if (goGitCloner{}).Exists(ctx, t.TempDir()) {
+		t.Error("Exists() = true for an empty directory")
+	}
+}
+
+func TestSSHUser(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"git@github.com:user/repo.git", "git"},
+		{"deploy@git.example.com:team/repo.git", "deploy"},
+		{"ssh://alice@git.example.com:2222/repo.git", "alice"},
+		{"ssh://git.example.com/repo.git", "git"},
+		{"github.com:user/repo.git", "git"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := sshUser(tt.url); got != tt.expected {
+				t.Errorf("sshUser() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRunGoGetParallelSameRepository(t *testing.T) {
+	gopath := t.TempDir()
+	fake := &fakeCloner{repos: map[string]map[string]string{
+		"git@github.com:aws/sdk.git": {
+			"go.mod":        "module github.com/aws/sdk\n",
+			"config/go.mod": "module github.com/aws/sdk/config\n",
+			"s3/go.mod":     "module github.com/aws/sdk/s3\n",
+		},
+	}}
+	deps := []string{"github.com/aws/sdk", "github.com/aws/sdk/config", "github.com/aws/sdk/s3"}
+	results := runGoGetParallel(context.Background(), deps, gopath, gopath, Options{Cloner: fake})
+
+	skipped := 0
+	for _, result := range results {
+		if result.Error != nil {
+			t.Errorf("%s: %v", result.ImportPath, result.Error)
+		}
+		if result.Skipped {
+			skipped++
+		}
+	}
+	if len(fake.clones) != 1 {
+		t.Errorf("clones = %v, want one", fake.clones)
+	}
+	if skipped != len(deps)-1 {
+		t.Errorf("%d dependencies skipped, want %d", skipped, len(deps)-1)
+	}
+}
+
+func TestRunGoGetWithoutGit(t *testing.T) {
+	old := gitInstalled
+	gitInstalled = func() bool { return false }
+	t.Cleanup(func() { gitInstalled = old })
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	gopath := t.TempDir()
+	fake := &fakeCloner{repos: map[string]map[string]string{
+		"https://github.com/user/repo.git": {"go.mod": "module github.com/user/repo\n"},
+	}}
+	opts := Options{Cloner: fake, HTTPS: true, FixRemotes: true, Update: true}
+	// The second run finds the clone, whose remote and update steps need git
+	for range 2 {
+		if _, err := runGoGet(context.Background(), "github.com/user/repo", gopath, gopath, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"not checking the origin", "not updating"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs %q do not contain %q", logs.String(), want)
+		}
+	}
+	if strings.Contains(logs.String(), "has no origin remote") {
+		t.Errorf("logs %q blame the clone for the missing git binary", logs.String())
+	}
+
+	if !(Options{Cloner: goGitCloner{}}).recursesSubmodules() || (Options{}).recursesSubmodules() {
+		t.Error("only the go-git backend should check out submodules while cloning")
+	}
+}
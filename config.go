@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UserConfig is goget's configuration file, by default config.json in
+// goget's directory under the user configuration directory:
+//
+//	{
+//	  "hosts": {
+//	    "github.com/acme": {"identityFile": "~/.ssh/acme_deploy"},
+//	    "git.example.com:2222": {"identityFile": "~/.ssh/example"},
+//	    "git.example.com": {"tokenEnv": "EXAMPLE_TOKEN", "username": "ci"},
+//	    "gitlab.com": {"protocol": "https"},
+//	    "go.googlesource.com": {"mirrors": ["https://gitea.internal/googlesource", "upstream", "https://proxy.internal/googlesource"]}
+//	  },
+//	  "urls": {
+//	    "https://git.internal/mirror/ourorg/": {"insteadOf": ["https://github.com/ourorg/", "git@github.com:ourorg/"]}
+//	  }
+//	}
+type UserConfig struct {
+	// Hosts holds settings for remotes, keyed by a host, host:port, or host
+	// and path prefix such as "github.com/acme". The longest key matching a
+	// remote wins.
+	Hosts map[string]HostConfig `json:"hosts"`
+
+	// URLs rewrites clone URLs like git's url.<base> sections, keyed by the
+	// base. They apply before git's own insteadOf rules.
+	URLs map[string]URLRules `json:"urls"`
+}
+
+// HostConfig holds the settings for the remotes matching one key of
+// UserConfig.Hosts
+type HostConfig struct {
+	IdentityFile string `json:"identityFile,omitempty"` // SSH private key to use, instead of ssh's defaults
+	TokenEnv     string `json:"tokenEnv,omitempty"`     // environment variable holding an HTTPS access token
+	Username     string `json:"username,omitempty"`     // username to send with the token; default x-access-token
+	Protocol     string `json:"protocol,omitempty"`     // "ssh" or "https" to clone with first; default ssh, then https
+
+	// Mirrors lists other places to clone from, tried in order. Each is a
+	// base URL that the rest of the repository path is appended to, or
+	// "upstream" for the repository's own URL, which is otherwise tried
+	// last.
+	Mirrors []string `json:"mirrors,omitempty"`
+}
+
+// defaultConfigPath returns where goget looks for its configuration file
+// when --config is not given
+func defaultConfigPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "goget", "config.json"), nil
+}
+
+// loadUserConfig reads the configuration file at path. A missing file is an
+// empty configuration unless required is set.
+func loadUserConfig(path string, required bool) (*UserConfig, error) {
+	config := &UserConfig{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	for key, host := range config.Hosts {
+		if err := validProtocol(host.Protocol); err != nil {
+			return nil, fmt.Errorf("%s: hosts[%q]: %w", path, key, err)
+		}
+		if host.IdentityFile != "" {
+			identity, err := expandHome(host.IdentityFile)
+			if err != nil {
+				return nil, fmt.Errorf("%s: hosts[%q]: %w", path, key, err)
+			}
+			host.IdentityFile = identity
+			config.Hosts[key] = host
+		}
+	}
+	return config, nil
+}
+
+// expandHome replaces a leading ~/ in path with the user's home directory
+func expandHome(path string) (string, error) {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, rest), nil
+}
+
+// remoteKey returns repoURL as host[:port]/path, the form UserConfig.Hosts
+// keys are matched against. The user, the scheme and a .git suffix are
+// dropped, and the port is kept only for ssh:// URLs, where it selects a
+// different server.
+func remoteKey(repoURL string) string {
+	var hostPort, path string
+	if scheme, rest, ok := strings.Cut(repoURL, "://"); ok {
+		hostPort, path, _ = strings.Cut(rest, "/")
+		if at := strings.LastIndex(hostPort, "@"); at != -1 {
+			hostPort = hostPort[at+1:]
+		}
+		if scheme != "ssh" && scheme != "git+ssh" {
+			hostPort, _, _ = strings.Cut(hostPort, ":")
+		}
+	} else if m := scpLikeURL.FindStringSubmatch(repoURL); m != nil {
+		hostPort, path = m[1], m[2]
+	} else {
+		return ""
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return strings.ToLower(hostPort) + "/" + path
+}
+
+// host returns the settings for repoURL: those of the longest key in Hosts
+// that is the host, host:port or a path prefix of repoURL. A key without a
+// port matches every port of its host.
+func (c *UserConfig) host(repoURL string) HostConfig {
+	settings, _ := c.match(repoURL)
+	return settings
+}
+
+// match is like host, and also returns the rest of repoURL's path after the
+// key that matched, e.g. "widget" for github.com/acme/widget.git and the key
+// "github.com/acme"
+func (c *UserConfig) match(repoURL string) (settings HostConfig, rest string) {
+	if c == nil {
+		return HostConfig{}, ""
+	}
+	key := remoteKey(repoURL)
+	if key == "" {
+		return HostConfig{}, ""
+	}
+	hostPort, path, _ := strings.Cut(key, "/")
+	host, _, _ := strings.Cut(hostPort, ":")
+	candidates := []string{key, host + "/" + path}
+
+	bestLen := -1
+	for prefix, s := range c.Hosts {
+		prefix = strings.ToLower(strings.Trim(prefix, "/"))
+		for _, candidate := range candidates {
+			if (candidate == prefix || strings.HasPrefix(candidate, prefix+"/")) && len(prefix) > bestLen {
+				settings, bestLen = s, len(prefix)
+				rest = strings.TrimPrefix(candidate[len(prefix):], "/")
+			}
+		}
+	}
+	return settings, rest
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRemoteKey(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"git@github.com:acme/repo.git", "github.com/acme/repo"},
+		{"https://github.com/acme/repo.git", "github.com/acme/repo"},
+		{"https://GitHub.com/acme/repo", "github.com/acme/repo"},
+		{"ssh://git@git.example.com:2222/team/repo.git", "git.example.com:2222/team/repo"},
+		{"https://git.example.com:8443/team/repo.git", "git.example.com/team/repo"},
+		{"/srv/git/repo.git", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := remoteKey(tt.url); got != tt.expected {
+				t.Errorf("remoteKey(%q) = %q, want %q", tt.url, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUserConfigHost(t *testing.T) {
+	config := &UserConfig{Hosts: map[string]HostConfig{
+		"github.com":           {IdentityFile: "/keys/default"},
+		"github.com/acme":      {IdentityFile: "/keys/acme"},
+		"github.com/acme/web":  {IdentityFile: "/keys/acme-web"},
+		"git.example.com":      {IdentityFile: "/keys/example"},
+		"git.example.com:2222": {IdentityFile: "/keys/example-2222"},
+	}}
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"git@github.com:other/repo.git", "/keys/default"},
+		{"git@github.com:acme/api.git", "/keys/acme"},
+		{"git@github.com:acme/web.git", "/keys/acme-web"},
+		{"git@github.com:acme/website.git", "/keys/acme"},
+		{"git@github.com:acmeco/repo.git", "/keys/default"},
+		{"ssh://git@git.example.com:2222/team/repo.git", "/keys/example-2222"},
+		{"ssh://git@git.example.com:2200/team/repo.git", "/keys/example"},
+		{"git@gitlab.com:acme/repo.git", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := config.host(tt.url).IdentityFile; got != tt.expected {
+				t.Errorf("host(%q).IdentityFile = %q, want %q", tt.url, got, tt.expected)
+			}
+		})
+	}
+
+	var empty *UserConfig
+	if got := empty.host("git@github.com:acme/api.git"); !reflect.DeepEqual(got, HostConfig{}) {
+		t.Errorf("nil config host() = %+v, want zero value", got)
+	}
+}
+
+func TestLoadUserConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := t.TempDir()
+
+	config, err := loadUserConfig(filepath.Join(dir, "missing.json"), false)
+	if err != nil || len(config.Hosts) != 0 {
+		t.Errorf("loadUserConfig(missing) = %+v, %v, want an empty config", config, err)
+	}
+	if _, err := loadUserConfig(filepath.Join(dir, "missing.json"), true); err == nil {
+		t.Error("loadUserConfig(missing, required) succeeded, want an error")
+	}
+
+	path := filepath.Join(dir, "config.json")
+	os.WriteFile(path, []byte(`{"hosts": {"github.com/acme": {"identityFile": "~/.ssh/acme"}}}`), 0644)
+	config, err = loadUserConfig(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Hosts["github.com/acme"].IdentityFile, filepath.Join(home, ".ssh", "acme"); got != want {
+		t.Errorf("IdentityFile = %q, want %q", got, want)
+	}
+
+	os.WriteFile(path, []byte(`{"hosts": {"github.com": {"protocol": "git"}}}`), 0644)
+	if _, err := loadUserConfig(path, true); err == nil {
+		t.Error("loadUserConfig(unknown protocol) succeeded, want an error")
+	}
+
+	os.WriteFile(path, []byte(`{"hosts": [`), 0644)
+	if _, err := loadUserConfig(path, true); err == nil {
+		t.Error("loadUserConfig(invalid JSON) succeeded, want an error")
+	}
+}
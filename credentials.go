@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// hostToken names the environment variable holding an access token for a
+// host, and the username the host expects alongside it
+type hostToken struct {
+	Env      string
+	Username string
+}
+
+// defaultTokens lists the environment variables goget reads tokens for the
+// major forges from, unless the configuration names others
+var defaultTokens = map[string]hostToken{
+	"github.com":    {Env: "GITHUB_TOKEN", Username: "x-access-token"},
+	"gitlab.com":    {Env: "GITLAB_TOKEN", Username: "oauth2"},
+	"bitbucket.org": {Env: "BITBUCKET_TOKEN", Username: "x-token-auth"},
+}
+
+// credentialHelper is the helper goget installs for hosts with a token. It
+// prints the username and token from the environment of the git process, so
+// the token is never in a URL, on a command line or in a config file. Each
+// host gets its own pair of variables, so a command can talk to several.
+const credentialHelper = `!f() { test "$1" = get || exit 0; printf 'username=%%s\npassword=%%s\n' "$GOGET_USERNAME_%[1]d" "$GOGET_TOKEN_%[1]d"; }; f`
+
+// httpsToken returns the username and token to authenticate to repoURL
+// over HTTPS with, or "" if there is none. A tokenEnv in the configuration
+// overrides the variable for the major forges.
+func httpsToken(repoURL string, opts Options) (username, token string) {
+	if !strings.HasPrefix(repoURL, "https://") {
+		return "", ""
+	}
+	host := strings.ToLower(extractHostFromGitURL(repoURL))
+	source := defaultTokens[host]
+	if settings := opts.UserConfig.host(repoURL); settings.TokenEnv != "" {
+		source = hostToken{Env: settings.TokenEnv, Username: settings.Username}
+	}
+	if source.Env == "" {
+		return "", ""
+	}
+	token = os.Getenv(source.Env)
+	if source.Username == "" {
+		// Accepted with a token by most forges and by Gitea
+		source.Username = "x-access-token"
+	}
+	return source.Username, token
+}
+
+// credentialEnv returns env with a credential helper for each host of
+// repoURLs that has a token, set through git's GIT_CONFIG_COUNT variables
+// after any the user already set. The helper replaces the user's helpers for
+// that host only.
+func credentialEnv(env []string, repoURLs []string, opts Options) []string {
+	count := 0
+	for _, kv := range env {
+		if value, ok := strings.CutPrefix(kv, "GIT_CONFIG_COUNT="); ok {
+			count, _ = strconv.Atoi(value)
+		}
+	}
+
+	var hosts []string
+	for _, repoURL := range repoURLs {
+		username, token := httpsToken(repoURL, opts)
+		host := extractHostFromGitURL(repoURL)
+		if token == "" || slices.Contains(hosts, host) {
+			continue
+		}
+		hosts = append(hosts, host)
+		key := fmt.Sprintf("credential.https://%s.helper", host)
+		n := len(hosts)
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", count, key),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=", count), // resets the list of helpers
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", count+1, key),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", count+1, fmt.Sprintf(credentialHelper, n)),
+			fmt.Sprintf("GOGET_USERNAME_%d=%s", n, username),
+			fmt.Sprintf("GOGET_TOKEN_%d=%s", n, token),
+		)
+		count += 2
+	}
+	if len(hosts) > 0 {
+		env = append(env, "GIT_CONFIG_COUNT="+strconv.Itoa(count))
+	}
+	return env
+}
+
+// remoteEnv returns env for a git command in dir that talks to repoURL, and
+// possibly to extraURLs: ssh set up by sshCommand, credential helpers for
+// the hosts with tokens, and no terminal prompts, which would hang a
+// parallel run. A nil env means the current process's environment.
+func remoteEnv(ctx context.Context, env []string, dir, repoURL string, opts Options, extraURLs ...string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+	env = append(env, "GIT_TERMINAL_PROMPT=0", "GIT_SSH_COMMAND="+sshCommand(ctx, dir, repoURL, opts))
+	return credentialEnv(env, append([]string{repoURL}, extraURLs...), opts)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestHTTPSToken(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "gh-token")
+	t.Setenv("GITLAB_TOKEN", "")
+	t.Setenv("ACME_TOKEN", "acme-token")
+	opts := Options{UserConfig: &UserConfig{Hosts: map[string]HostConfig{
+		"github.com/acme": {TokenEnv: "ACME_TOKEN"},
+		"git.example.com": {TokenEnv: "ACME_TOKEN", Username: "ci"},
+	}}}
+	tests := []struct {
+		url            string
+		expectUsername string
+		expectToken    string
+	}{
+		{"https://github.com/user/repo.git", "x-access-token", "gh-token"},
+		{"https://github.com/acme/repo.git", "x-access-token", "acme-token"},
+		{"https://git.example.com/team/repo.git", "ci", "acme-token"},
+		{"https://gitlab.com/user/repo.git", "oauth2", ""},
+		{"https://bitbucket.example.com/user/repo.git", "", ""},
+		{"git@github.com:user/repo.git", "", ""},
+	}
+	for _, tt := range tests {
+		username, token := httpsToken(tt.url, opts)
+		if token != tt.expectToken || (token != "" && username != tt.expectUsername) {
+			t.Errorf("httpsToken(%q) = %q, %q, want %q, %q", tt.url, username, token, tt.expectUsername, tt.expectToken)
+		}
+	}
+}
+
+// TestCredentialHelper asks git for credentials the way a clone would, with
+// the helper goget installs
+func TestCredentialHelper(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	isolateGitConfig(t)
+	t.Setenv("GITHUB_TOKEN", "gh-token")
+	t.Setenv("GIT_TOKEN", "gitea-token")
+	opts := Options{UserConfig: &UserConfig{Hosts: map[string]HostConfig{
+		"git.example.com": {TokenEnv: "GIT_TOKEN", Username: "ci"},
+	}}}
+
+	// The user's own GIT_CONFIG_* settings are kept
+	env := []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=user.name", "GIT_CONFIG_VALUE_0=Tester"}
+	env = credentialEnv(append(isolatedEnv(), env...), []string{
+		"https://github.com/user/repo.git",
+		"https://git.example.com/team/repo.git",
+		"https://gitlab.com/user/repo.git",
+	}, opts)
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GIT_CONFIG_VALUE_") && strings.Contains(kv, "-token") {
+			t.Errorf("token in git configuration: %s", kv)
+		}
+	}
+
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"github.com", "username=x-access-token\npassword=gh-token\n"},
+		{"git.example.com", "username=ci\npassword=gitea-token\n"},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command("git", "credential", "fill")
+		cmd.Env = append(env, "GIT_TERMINAL_PROMPT=0")
+		cmd.Stdin = strings.NewReader("protocol=https\nhost=" + tt.host + "\n\n")
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git credential fill for %s: %v", tt.host, err)
+		}
+		if !strings.Contains(string(out), tt.expected) {
+			t.Errorf("credentials for %s = %q, want %q", tt.host, out, tt.expected)
+		}
+	}
+
+	// No token, no helper: git would prompt, and fails instead
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Env = append(env, "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=gitlab.com\n\n")
+	if out, err := cmd.Output(); err == nil {
+		t.Errorf("got credentials for gitlab.com without a token: %q", out)
+	}
+
+	cmd = exec.Command("git", "config", "user.name")
+	cmd.Env = env
+	if out, err := cmd.Output(); err != nil || strings.TrimSpace(string(out)) != "Tester" {
+		t.Errorf("user.name = %q, %v, want Tester", out, err)
+	}
+}
+
+// isolatedEnv returns the environment isolateGitConfig set up, for commands
+// that are given an explicit environment
+func isolatedEnv() []string {
+	var env []string
+	for _, key := range []string{"PATH", "HOME", "GIT_CONFIG_GLOBAL", "GIT_CONFIG_NOSYSTEM"} {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Causes of a failed clone or fetch. A *GitError unwraps to one of them, so
+// callers can test the cause with errors.Is.
+var (
+	ErrHostKeyUnknown    = errors.New("SSH host key unknown")
+	ErrHostKeyChanged    = errors.New("SSH host key changed")
+	ErrAuthDenied        = errors.New("authentication denied")
+	ErrNotFound          = errors.New("repository not found")
+	ErrNetwork           = errors.New("network unreachable")
+	ErrServer            = errors.New("server error")
+	ErrTLS               = errors.New("TLS failure")
+	ErrDestinationExists = errors.New("destination exists")
+	ErrFsck              = errors.New("fsck failure")
+	ErrCancelled         = errors.New("cancelled")
+)
+
+// errorCauses lists the causes in the order the summary groups them
+var errorCauses = []error{
+	ErrHostKeyUnknown, ErrHostKeyChanged, ErrAuthDenied, ErrNotFound,
+	ErrNetwork, ErrServer, ErrTLS, ErrDestinationExists, ErrFsck, ErrCancelled,
+}
+
+// outputPatterns maps lines of git's error output to causes. The first match
+// wins, so more specific patterns come first.
+var outputPatterns = []struct {
+	cause    error
+	patterns []*regexp.Regexp
+}{
+	{ErrHostKeyChanged, patterns(`REMOTE HOST IDENTIFICATION HAS CHANGED`, `^Host key for .+ has changed`, `has changed and you have requested strict checking`)},
+	{ErrHostKeyUnknown, patterns(`^Host key verification failed`, `^No \w+ host key is known for`)},
+	{ErrFsck, patterns(`^(error|fatal): .*fsck`, `fsckObjects`)},
+	{ErrTLS, patterns(`SSL certificate problem`, `server certificate verification failed`, `certificate verify failed`, `gnutls_handshake`, `SSL_connect`, `SSL_ERROR`)},
+	{ErrAuthDenied, patterns(`^\S+: Permission denied \(`, `Authentication failed for`, `could not read Username`, `could not read Password`, `terminal prompts disabled`, `HTTP 40[13]`, `returned error: 40[13]`)},
+	{ErrNotFound, patterns(`^(remote: )?(ERROR: )?Repository not found`, `^fatal: repository '.+' not found$`, `^(remote: )?ERROR: .*does not exist`, `does not appear to be a git repository$`, `HTTP 404`, `returned error: 404`)},
+	{ErrServer, patterns(`returned error: 5\d\d`, `HTTP 5\d\d`, `status code: 5\d\d`, `Internal Server Error`, `Bad Gateway`, `Service Unavailable`, `Gateway Timeout`)},
+	{ErrNetwork, patterns(`early EOF`, `unexpected disconnect`, `RPC failed`, `Could not resolve host`, `Connection refused`, `Connection timed out`, `Operation timed out`, `Network is unreachable`, `Connection reset`, `Connection closed`, `kex_exchange_identification`)},
+	{ErrDestinationExists, patterns(`already exists and is not an empty directory`)},
+}
+
+// networkFallbackPatterns match the lines git prints after any failure to
+// talk to a remote. They only stand for ErrNetwork when no other line says
+// what went wrong.
+var networkFallbackPatterns = patterns(`Could not read from remote repository`, `remote end hung up unexpectedly`, `^fatal: unable to access`)
+
+// patterns compiles regular expressions for outputPatterns
+func patterns(exprs ...string) []*regexp.Regexp {
+	var res []*regexp.Regexp
+	for _, expr := range exprs {
+		res = append(res, regexp.MustCompile(expr))
+	}
+	return res
+}
+
+// isErrorLine reports whether line is one of git's or the server's own error
+// messages, rather than a warning or progress from ssh or curl
+func isErrorLine(line string) bool {
+	line = strings.TrimPrefix(line, "remote: ")
+	return strings.HasPrefix(line, "fatal: ") || strings.HasPrefix(line, "error: ") || strings.HasPrefix(line, "ERROR: ")
+}
+
+// lineCause returns the cause a line of git's error output shows, or nil
+func lineCause(line string) error {
+	for _, p := range outputPatterns {
+		if slices.ContainsFunc(p.patterns, func(re *regexp.Regexp) bool { return re.MatchString(line) }) {
+			return p.cause
+		}
+	}
+	return nil
+}
+
+// classifyLines returns the cause of a failure from git's error output, and
+// the line that shows it. git's and the server's error lines are tried first,
+// so a warning such as ssh's "Permanently added ... host key" can't mask
+// them, then the other lines, and last the generic lines that follow any
+// failure to reach a remote. It returns nil if it does not recognize any.
+func classifyLines(output string) (cause error, line string) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	for _, errorLines := range []bool{true, false} {
+		for _, line := range lines {
+			if isErrorLine(line) == errorLines {
+				if cause := lineCause(line); cause != nil {
+					return cause, line
+				}
+			}
+		}
+	}
+	for _, line := range lines {
+		if slices.ContainsFunc(networkFallbackPatterns, func(re *regexp.Regexp) bool { return re.MatchString(line) }) {
+			return ErrNetwork, line
+		}
+	}
+	return nil, ""
+}
+
+// classifyGitOutput returns the cause of a failure from git's error output,
+// or nil if it does not recognize it
+func classifyGitOutput(output string) error {
+	cause, _ := classifyLines(output)
+	return cause
+}
+
+// GitError is a failed git command, classified by cause
+type GitError struct {
+	Kind   error    // one of the Err* causes, or nil if unknown
+	URL    string   // remote the command talked to, if any
+	Args   []string // git arguments
+	Output string   // git's whole standard error, trimmed
+	Err    error    // the error from running git
+}
+
+func (e *GitError) Error() string {
+	msg := fmt.Sprintf("git %s: %v", strings.Join(e.Args, " "), e.Err)
+	if line := errorLine(e.Output, e.Kind); line != "" {
+		msg += ": " + line
+	}
+	if e.Kind != nil {
+		msg = e.Kind.Error() + ": " + msg
+	}
+	return msg
+}
+
+func (e *GitError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// newGitError classifies a git command that failed with err and printed
+// output to standard error. If ctx is done, the cause is ErrCancelled.
+func newGitError(ctx context.Context, url string, args []string, output string, err error) *GitError {
+	kind := classifyGitOutput(output)
+	if ctx.Err() != nil {
+		kind = ErrCancelled
+	}
+	return &GitError{Kind: kind, URL: url, Args: args, Output: strings.TrimSpace(output), Err: err}
+}
+
+// errorLine returns the line of git's error output that explains a failure
+// with the given cause: the line it was classified by, or else the last line,
+// which is usually the fatal error
+func errorLine(output string, kind error) string {
+	if cause, line := classifyLines(output); cause != nil && cause == kind {
+		return line
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// classifyGoGitError returns the cause of a go-git failure, or nil
+func classifyGoGitError(err error) error {
+	var keyErr *knownhosts.KeyError
+	var netErr net.Error
+	switch {
+	case errorCause(err) != nil:
+		return errorCause(err)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrCancelled
+	case errors.As(err, &keyErr):
+		if len(keyErr.Want) > 0 {
+			return ErrHostKeyChanged
+		}
+		return ErrHostKeyUnknown
+	case errors.Is(err, transport.ErrAuthenticationRequired), errors.Is(err, transport.ErrAuthorizationFailed),
+		strings.Contains(err.Error(), "unable to authenticate"):
+		return ErrAuthDenied
+	case errors.Is(err, transport.ErrRepositoryNotFound):
+		return ErrNotFound
+	case isTLSError(err):
+		return ErrTLS
+	case errors.As(err, &netErr):
+		return ErrNetwork
+	}
+	return classifyGitOutput(err.Error())
+}
+
+// isTLSError reports whether err is a failed TLS handshake or certificate
+// check, which fails the same way on every attempt
+func isTLSError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var certInvalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	var verification *tls.CertificateVerificationError
+	var alert tls.AlertError
+	var recordHeader tls.RecordHeaderError
+	return errors.As(err, &unknownAuthority) || errors.As(err, &certInvalid) || errors.As(err, &hostname) ||
+		errors.As(err, &verification) || errors.As(err, &alert) || errors.As(err, &recordHeader)
+}
+
+// errorCause returns the cause err unwraps to, or nil
+func errorCause(err error) error {
+	for _, cause := range errorCauses {
+		if errors.Is(err, cause) {
+			return cause
+		}
+	}
+	return nil
+}
+
+// causeName describes the cause of err for logs and the summary
+func causeName(err error) string {
+	if cause := errorCause(err); cause != nil {
+		return cause.Error()
+	}
+	return "other error"
+}
+
+// sshFallbackWorthwhile reports whether a failed SSH clone might work over
+// HTTPS. A missing repository or an interrupted run won't, and a changed host
+// key should be looked into rather than routed around.
+func sshFallbackWorthwhile(err error) bool {
+	switch errorCause(err) {
+	case nil, ErrHostKeyUnknown, ErrAuthDenied, ErrNetwork:
+		return true
+	}
+	return false
+}
+
+// failureGroup is the failed results with one cause
+type failureGroup struct {
+	Cause   error // nil for failures with no known cause
+	Results []DependencyResult
+}
+
+// groupFailures groups the failed results by cause, in the order of
+// errorCauses, with unclassified failures last
+func groupFailures(results []DependencyResult) []failureGroup {
+	var groups []failureGroup
+	for _, cause := range append(slices.Clone(errorCauses), nil) {
+		group := failureGroup{Cause: cause}
+		for _, result := range results {
+			if result.Error != nil && errorCause(result.Error) == cause {
+				group.Results = append(group.Results, result)
+			}
+		}
+		if len(group.Results) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// errorHosts returns the hosts of the remotes in errs, in order, without
+// duplicates. SSH servers on a non-default port are given as host:port.
+func errorHosts(errs []error) []string {
+	var hosts []string
+	for _, err := range errs {
+		var gitErr *GitError
+		if errors.As(err, &gitErr) && gitErr.URL != "" {
+			host := extractHostFromGitURL(gitErr.URL)
+			if port := sshPort(gitErr.URL); port != "" {
+				host += ":" + port
+			}
+			if host != "" && !slices.Contains(hosts, host) {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	return hosts
+}
+
+// causeHint returns advice for fixing failures with the given cause on
+// hosts, or "" if there is none
+func causeHint(cause error, hosts []string) string {
+	host, hostList := "<host>", strings.Join(hosts, ", ")
+	if len(hosts) == 1 {
+		host = hosts[0]
+	}
+	portFlag, knownHost := "", host
+	if h, port, ok := strings.Cut(host, ":"); ok {
+		host, portFlag, knownHost = h, "-p "+port+" ", "'["+h+"]:"+port+"'"
+	}
+	if hostList == "" {
+		hostList = "the host"
+	}
+	switch cause {
+	case ErrHostKeyUnknown:
+		hint := fmt.Sprintf("SSH host key verification failed for %s.\n", hostList)
+		hint += "To fix this, you can:\n"
+		hint += fmt.Sprintf("  1. Add the host to known_hosts: ssh-keyscan %s%s >> ~/.ssh/known_hosts\n", portFlag, host)
+		hint += "  2. Connect manually once: ssh " + portFlag + "-T git@" + host + "\n"
+		hint += "  3. Use --accept-ssh-host flag to auto-accept new host keys\n"
+		hint += "  4. Use --https flag to clone via HTTPS instead\n"
+		return hint
+	case ErrHostKeyChanged:
+		hint := fmt.Sprintf("The SSH host key of %s does not match known_hosts. This could mean someone is\n", hostList)
+		hint += "intercepting the connection, or that the host rotated its keys. Check the host's\n"
+		hint += fmt.Sprintf("published fingerprints before removing the old key with: ssh-keygen -R %s\n", knownHost)
+		return hint
+	case ErrAuthDenied:
+		hint := "Check that your SSH key is loaded (ssh-add -l) and has access to the repository,\n"
+		hint += "set an identityFile for the host in goget's config, or use --https for public\nrepositories.\n"
+		return hint
+	case ErrTLS:
+		return "The server's TLS certificate could not be verified. Check the system clock and CA certificates.\n"
+	case ErrFsck:
+		return "The repository contains objects that fail git's fsck checks. Use --skip-fsck to clone it anyway.\n"
+	case ErrDestinationExists:
+		return "Use --adopt to turn existing directories into clones.\n"
+	}
+	return ""
+}
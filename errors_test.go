@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestClassifyGitOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected error
+	}{
+		{"host key unknown", "Host key verification failed.\nfatal: Could not read from remote repository.", ErrHostKeyUnknown},
+		{"host key changed", "@@@@@@@@@@@\n@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @\nHost key verification failed.", ErrHostKeyChanged},
+		{"publickey", "git@github.com: Permission denied (publickey).\nfatal: Could not read from remote repository.", ErrAuthDenied},
+		{"https prompt", "fatal: could not read Username for 'https://github.com': terminal prompts disabled", ErrAuthDenied},
+		{"missing repository", "remote: Repository not found.\nfatal: repository 'https://github.com/user/missing/' not found", ErrNotFound},
+		{"dns", "fatal: unable to access 'https://git.invalid/repo/': Could not resolve host: git.invalid", ErrNetwork},
+		{"kex", "kex_exchange_identification: read: Connection reset by peer\nfatal: Could not read from remote repository.", ErrNetwork},
+		{"server error", "error: RPC failed; HTTP 502 curl 22 The requested URL returned error: 502\nfatal: expected flush after ref listing", ErrServer},
+		{"early eof", "fetch-pack: unexpected disconnect while reading sideband packet\nfatal: early EOF", ErrNetwork},
+		{"tls", "fatal: unable to access 'https://git.example.com/repo/': SSL certificate problem: self-signed certificate", ErrTLS},
+		{"fsck", "error: object 1234: badTimezone: invalid author/committer line - bad time zone\nfatal: fsck error in packed object", ErrFsck},
+		{"destination", "fatal: destination path 'repo' already exists and is not an empty directory.", ErrDestinationExists},
+		{"ssh warning before a missing repository", "Warning: Permanently added the RSA host key for IP address '140.82.112.3' to the list of known hosts.\nERROR: Repository not found.\nfatal: Could not read from remote repository.\n\nPlease make sure you have the correct access rights\nand the repository exists.", ErrNotFound},
+		{"connection closed", "Connection closed by 140.82.112.3 port 22\nfatal: Could not read from remote repository.", ErrNetwork},
+		{"only the generic trailer", "fatal: Could not read from remote repository.", ErrNetwork},
+		{"missing branch", "warning: Could not find remote branch nope to clone.\nfatal: Remote branch nope not found in upstream origin", nil},
+		{"local permission denied", "fatal: could not create work tree dir 'repo': Permission denied", nil},
+		{"other", "fatal: something else", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyGitOutput(tt.output); got != tt.expected {
+				t.Errorf("classifyGitOutput() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGitErrorUnwrap(t *testing.T) {
+	exitErr := &exec.ExitError{}
+	err := fmt.Errorf("could not clone: %w", &GitError{Kind: ErrAuthDenied, Args: []string{"clone"}, Err: exitErr})
+	if !errors.Is(err, ErrAuthDenied) {
+		t.Error("errors.Is(err, ErrAuthDenied) = false")
+	}
+	var target *exec.ExitError
+	if !errors.As(err, &target) {
+		t.Error("errors.As(err, *exec.ExitError) = false")
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Error("errors.Is(err, ErrNotFound) = true")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := newGitError(ctx, "", nil, "fatal: early EOF", exitErr); !errors.Is(got, ErrCancelled) {
+		t.Errorf("newGitError() with cancelled context = %v, want ErrCancelled", got)
+	}
+}
+
+func TestSSHFallbackWorthwhile(t *testing.T) {
+	tests := []struct {
+		cause    error
+		expected bool
+	}{
+		{nil, true},
+		{ErrHostKeyUnknown, true},
+		{ErrAuthDenied, true},
+		{ErrNetwork, true},
+		{ErrHostKeyChanged, false},
+		{ErrNotFound, false},
+		{ErrCancelled, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.cause), func(t *testing.T) {
+			err := &GitError{Kind: tt.cause, Err: errors.New("exit status 128")}
+			if got := sshFallbackWorthwhile(err); got != tt.expected {
+				t.Errorf("sshFallbackWorthwhile() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGroupFailures(t *testing.T) {
+	results := []DependencyResult{
+		{ImportPath: "a", Error: &GitError{Kind: ErrNotFound, Err: errors.New("exit status 128")}},
+		{ImportPath: "b"},
+		{ImportPath: "c", Error: errors.New("could not determine git URL")},
+		{ImportPath: "d", Error: fmt.Errorf("wrapped: %w", &GitError{Kind: ErrAuthDenied, Err: errors.New("exit status 128")})},
+		{ImportPath: "e", Error: &GitError{Kind: ErrNotFound, Err: errors.New("exit status 128")}},
+	}
+
+	var got []string
+	for _, group := range groupFailures(results) {
+		var paths []string
+		for _, result := range group.Results {
+			paths = append(paths, result.ImportPath)
+		}
+		got = append(got, fmt.Sprintf("%s: %v", causeName(group.Cause), paths))
+	}
+	expected := []string{"authentication denied: [d]", "repository not found: [a e]", "other error: [c]"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("groupFailures() = %v, want %v", got, expected)
+	}
+}
+
+func TestExecClonerClassifiesSSHFailures(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	tests := []struct {
+		name   string
+		stderr string
+		cause  error
+		line   string
+	}{
+		{
+			name:   "unknown host key",
+			stderr: "Host key verification failed.",
+			cause:  ErrHostKeyUnknown,
+			line:   "Host key verification failed.",
+		},
+		{
+			name:   "changed host key",
+			stderr: "@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@\\n@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @\\nHost key verification failed.",
+			cause:  ErrHostKeyChanged,
+			line:   "@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @",
+		},
+		{
+			name:   "missing repository after an ssh warning",
+			stderr: "Warning: Permanently added the ECDSA host key for IP address 192.0.2.1 to the list of known hosts.\\nERROR: Repository not found.",
+			cause:  ErrNotFound,
+			line:   "ERROR: Repository not found.",
+		},
+		{
+			name:   "auth denied",
+			stderr: "git@git.example.com: Permission denied (publickey).",
+			cause:  ErrAuthDenied,
+			line:   "git@git.example.com: Permission denied (publickey).",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateGitConfig(t)
+			dir := t.TempDir()
+			// Named ssh so git passes it OpenSSH's arguments
+			fakeSSH := filepath.Join(dir, "ssh")
+			script := "#!/bin/sh\nprintf '" + tt.stderr + "\\n' >&2\nexit 255\n"
+			if err := os.WriteFile(fakeSSH, []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("GIT_SSH_COMMAND", fakeSSH)
+
+			url := "git@git.example.com:team/repo.git"
+			cmd := &GitCommand{URL: url, Args: []string{"clone", "--quiet", url, filepath.Join(dir, "repo")}}
+			for name, run := range map[string]func() error{
+				"Clone": func() error {
+					return (execCloner{}).Clone(context.Background(), cmd, filepath.Join(dir, "tmp"), Options{})
+				},
+				"ListRemote": func() error {
+					_, err := (execCloner{}).ListRemote(context.Background(), url, Options{})
+					return err
+				},
+			} {
+				err := run()
+				if !errors.Is(err, tt.cause) {
+					t.Errorf("%s() = %v, want cause %v", name, err, tt.cause)
+				}
+				var gitErr *GitError
+				if !errors.As(err, &gitErr) {
+					continue
+				}
+				if !strings.Contains(gitErr.Output, "and the repository exists.") {
+					t.Errorf("%s() Output = %q, want git's whole error output", name, gitErr.Output)
+				}
+				if !strings.HasSuffix(err.Error(), ": "+tt.line) {
+					t.Errorf("%s() = %q, want it to end with %q", name, err, tt.line)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// Sources recorded in DependencyResult.Source for clones that did not come
+// from a mirror
+const (
+	sourceUpstream      = "upstream"
+	sourceUpstreamHTTPS = "upstream (HTTPS)"
+	sourceUpstreamSSH   = "upstream (SSH)"
+)
+
+// cloneCandidate is one place runGoGet may clone a repository from
+type cloneCandidate struct {
+	Source string // one of the sourceUpstream constants, or a mirror URL
+	// Cmd returns the command that clones from this source, given the error
+	// the previous candidate failed with (nil for the first one), or nil to
+	// skip it
+	Cmd func(prev error) *GitCommand
+}
+
+// mirrorURL returns the URL of the repository at path rest under the base
+// URL of a mirror
+func mirrorURL(base, rest string) string {
+	if rest == "" {
+		return base
+	}
+	// scp-like bases such as git@mirror: take the path as it is
+	if !strings.HasSuffix(base, ":") {
+		base = strings.TrimSuffix(base, "/") + "/"
+	}
+	return base + rest + ".git"
+}
+
+// cloneSources returns where to clone repoURL from, in order: the mirrors
+// goget's configuration lists for it, with sourceUpstream for repoURL
+// itself, which goes last unless the list places it
+func cloneSources(repoURL string, opts Options) []string {
+	settings, rest := opts.UserConfig.match(repoURL)
+	var sources []string
+	for _, mirror := range settings.Mirrors {
+		if mirror != sourceUpstream {
+			mirror = mirrorURL(mirror, rest)
+		}
+		if !slices.Contains(sources, mirror) {
+			sources = append(sources, mirror)
+		}
+	}
+	if !slices.Contains(sources, sourceUpstream) {
+		sources = append(sources, sourceUpstream)
+	}
+	return sources
+}
+
+// cloneCandidates returns the candidates for cloning with gitCmd, one per
+// source. Mirror clones are copies of gitCmd that fetch from the mirror and
+// leave origin pointing at gitCmd.URL. Upstream is followed by fallback,
+// which may retry it over the other protocol, recorded as fallbackSource.
+func cloneCandidates(gitCmd *GitCommand, sources []string, opts Options, fallbackSource string, fallback func(error) *GitCommand) []cloneCandidate {
+	var candidates []cloneCandidate
+	for _, source := range sources {
+		if source == sourceUpstream {
+			candidates = append(candidates,
+				cloneCandidate{Source: sourceUpstream, Cmd: func(error) *GitCommand { return gitCmd }},
+				cloneCandidate{Source: fallbackSource, Cmd: func(prev error) *GitCommand {
+					fallbackCmd := fallback(prev)
+					if fallbackCmd != nil {
+						fallbackCmd.setMode(gitCmd.Mode)
+					}
+					return fallbackCmd
+				}},
+			)
+			continue
+		}
+		mirrorCmd := *gitCmd
+		mirrorCmd.Args = slices.Clone(gitCmd.Args)
+		mirrorCmd.setRewrittenURL(source, opts)
+		mirrorCmd.OriginURL = gitCmd.gitURL()
+		candidates = append(candidates, cloneCandidate{Source: source, Cmd: func(error) *GitCommand { return &mirrorCmd }})
+	}
+	return candidates
+}
+
+// failoverWorthwhile reports whether a clone that failed with err might
+// succeed from another source. Cancellation and an existing destination
+// would stop them all.
+func failoverWorthwhile(err error) bool {
+	cause := errorCause(err)
+	return cause != ErrCancelled && cause != ErrDestinationExists
+}
+
+// cloneFromCandidates tries each candidate in order until a clone succeeds,
+// recording the source that worked in result. It returns the command that
+// cloned, or the error of the last one that was tried.
+func cloneFromCandidates(ctx context.Context, candidates []cloneCandidate, opts Options, result *DependencyResult) (cmd *GitCommand, skipped bool, err error) {
+	return tryCandidates(candidates, result, func(cmd *GitCommand) (bool, error) {
+		fmt.Printf("git %s\n", strings.Join(cmd.Args, " "))
+		return cloneWithRetry(ctx, cmd, opts, result)
+	})
+}
+
+// tryCandidates calls try with the command of each candidate in order until
+// one succeeds, and records its source in result. It returns that command,
+// or the last one tried and its error.
+func tryCandidates(candidates []cloneCandidate, result *DependencyResult, try func(*GitCommand) (skipped bool, err error)) (cmd *GitCommand, skipped bool, err error) {
+	var failed string
+	for _, candidate := range candidates {
+		if failed != "" && !failoverWorthwhile(err) {
+			break
+		}
+		next := candidate.Cmd(err)
+		if next == nil {
+			continue
+		}
+		if failed != "" {
+			log.Printf("%s failed (%v), trying %s...", failed, causeName(err), candidate.Source)
+		}
+		skipped, err = try(next)
+		if err == nil {
+			if !skipped {
+				result.Source = candidate.Source
+			}
+			return next, skipped, nil
+		}
+		cmd, failed = next, candidate.Source
+	}
+	return cmd, skipped, err
+}
+
+// printSourceSummary lists the dependencies that were cloned, or with
+// --check reached, from somewhere other than upstream
+func printSourceSummary(results []DependencyResult) {
+	var other []DependencyResult
+	for _, result := range results {
+		if result.Source != "" && result.Source != sourceUpstream {
+			other = append(other, result)
+		}
+	}
+	if len(other) == 0 {
+		return
+	}
+	fmt.Println("\nSources other than upstream:")
+	for _, result := range other {
+		fmt.Printf("  - %s: %s\n", result.ImportPath, result.Source)
+	}
+}
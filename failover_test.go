@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCloneSources(t *testing.T) {
+	config := &UserConfig{Hosts: map[string]HostConfig{
+		"github.com/acme":     {Mirrors: []string{"https://gitea.internal/acme/", "upstream", "git@proxy.internal:acme"}},
+		"gitlab.com":          {Mirrors: []string{"https://gitea.internal/gitlab"}},
+		"github.com/acme/web": {Mirrors: []string{"upstream", "https://gitea.internal/web.git"}},
+	}}
+	tests := []struct {
+		url      string
+		expected []string
+	}{
+		{"git@github.com:acme/api.git", []string{"https://gitea.internal/acme/api.git", "upstream", "git@proxy.internal:acme/api.git"}},
+		{"https://gitlab.com/team/sub/repo.git", []string{"https://gitea.internal/gitlab/team/sub/repo.git", "upstream"}},
+		{"git@github.com:acme/web.git", []string{"upstream", "https://gitea.internal/web.git"}},
+		{"git@github.com:other/repo.git", []string{"upstream"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := cloneSources(tt.url, Options{UserConfig: config}); !slices.Equal(got, tt.expected) {
+				t.Errorf("cloneSources(%q) = %v, want %v", tt.url, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRunGoGetFailover(t *testing.T) {
+	mirrors := &UserConfig{Hosts: map[string]HostConfig{
+		"github.com": {Mirrors: []string{"https://gitea.internal/github", "https://proxy.internal/github"}},
+	}}
+	tests := []struct {
+		name         string
+		opts         Options
+		repos        map[string]map[string]string
+		expectClones []string
+		expectSource string
+	}{
+		{
+			name: "first mirror",
+			opts: Options{HTTPS: true, UserConfig: mirrors},
+			repos: map[string]map[string]string{
+				"https://gitea.internal/github/user/repo.git": {"go.mod": "module github.com/user/repo\n"},
+			},
+			expectClones: []string{"https://gitea.internal/github/user/repo.git"},
+			expectSource: "https://gitea.internal/github/user/repo.git",
+		},
+		{
+			name: "second mirror",
+			opts: Options{HTTPS: true, UserConfig: mirrors},
+			repos: map[string]map[string]string{
+				"https://proxy.internal/github/user/repo.git": {"go.mod": "module github.com/user/repo\n"},
+			},
+			expectClones: []string{"https://gitea.internal/github/user/repo.git", "https://proxy.internal/github/user/repo.git"},
+			expectSource: "https://proxy.internal/github/user/repo.git",
+		},
+		{
+			name: "upstream after the mirrors",
+			opts: Options{HTTPS: true, UserConfig: mirrors},
+			repos: map[string]map[string]string{
+				"https://github.com/user/repo.git": {"go.mod": "module github.com/user/repo\n"},
+			},
+			expectClones: []string{"https://gitea.internal/github/user/repo.git", "https://proxy.internal/github/user/repo.git", "https://github.com/user/repo.git"},
+			expectSource: "upstream",
+		},
+		{
+			name: "HTTPS fallback is a candidate like the others",
+			repos: map[string]map[string]string{
+				"https://github.com/user/repo.git": {"go.mod": "module github.com/user/repo\n"},
+			},
+			expectClones: []string{"git@github.com:user/repo.git", "https://github.com/user/repo.git"},
+			expectSource: "upstream (HTTPS)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gopath := t.TempDir()
+			fake := &fakeCloner{repos: tt.repos}
+			opts := tt.opts
+			opts.Cloner = fake
+			result, err := runGoGet(context.Background(), "github.com/user/repo", gopath, gopath, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(fake.clones, tt.expectClones) {
+				t.Errorf("clones = %v, want %v", fake.clones, tt.expectClones)
+			}
+			if result.Source != tt.expectSource {
+				t.Errorf("Source = %q, want %q", result.Source, tt.expectSource)
+			}
+		})
+	}
+}
+
+func TestCloneFromMirrorResetsOrigin(t *testing.T) {
+	isolateGitConfig(t)
+	mirror := filepath.Join(t.TempDir(), "mirror")
+	initTestRepo(t, mirror)
+	dir := t.TempDir()
+
+	cmd := &GitCommand{
+		URL:        mirror,
+		TargetPath: dir,
+		Args:       []string{"clone", mirror, dir},
+		OriginURL:  "https://github.com/user/repo.git",
+	}
+	if err := (execCloner{}).Clone(context.Background(), cmd, dir, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := gitOutput(context.Background(), dir, "remote", "get-url", "origin"); got != cmd.OriginURL {
+		t.Errorf("origin = %q, want %q", got, cmd.OriginURL)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "doc.go")); err != nil {
+		t.Errorf("clone from the mirror has no files: %v", err)
+	}
+}
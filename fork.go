@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// upstreamRemoteName is the remote --fork clones the canonical repository
+// as; origin is the user's fork
+const upstreamRemoteName = "upstream"
+
+// forkURL returns the SSH URL of a fork of the repository at upstreamURL
+// owned by fork (a user or namespace), following the user/repo layout forges
+// use: https://github.com/owner/repo -> git@github.com:fork/repo.git
+func forkURL(upstreamURL, fork string) (string, error) {
+	httpsURL := strings.TrimSuffix(sshToHTTPS(upstreamURL), "/")
+	rest, ok := strings.CutPrefix(httpsURL, "https://")
+	if !ok {
+		return "", fmt.Errorf("cannot derive a fork URL from %s", upstreamURL)
+	}
+	host, path, ok := strings.Cut(rest, "/")
+	if !ok || path == "" {
+		return "", fmt.Errorf("cannot derive a fork URL from %s", upstreamURL)
+	}
+	name := path[strings.LastIndex(path, "/")+1:]
+	return httpsToSSH(fmt.Sprintf("https://%s/%s/%s", host, strings.Trim(fork, "/"), name)), nil
+}
+
+// configureFork wires up the clone in dir for the fork workflow: the
+// upstream remote points at upstreamURL (read-only HTTPS), origin points at
+// the fork, and pushes go to origin by default, at pushURL if it is set. It
+// works on fresh clones made with --origin upstream and on existing clones
+// whose origin is the upstream repository.
+func configureFork(ctx context.Context, dir, upstreamURL, forkURL, pushURL string) error {
+	remotes, err := gitOutput(ctx, dir, "remote")
+	if err != nil {
+		return err
+	}
+	has := func(name string) bool {
+		for remote := range strings.SplitSeq(remotes, "\n") {
+			if remote == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	originURL := ""
+	if has("origin") {
+		originURL, err = gitOutput(ctx, dir, "remote", "get-url", "origin")
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case has(upstreamRemoteName):
+		if _, err := gitOutput(ctx, dir, "remote", "set-url", upstreamRemoteName, upstreamURL); err != nil {
+			return err
+		}
+	case originURL != "" && sameRemote(originURL, upstreamURL):
+		// An existing clone of upstream; its origin becomes upstream
+		if _, err := gitOutput(ctx, dir, "remote", "rename", "origin", upstreamRemoteName); err != nil {
+			return err
+		}
+		if _, err := gitOutput(ctx, dir, "remote", "set-url", upstreamRemoteName, upstreamURL); err != nil {
+			return err
+		}
+		originURL = ""
+	default:
+		if _, err := gitOutput(ctx, dir, "remote", "add", upstreamRemoteName, upstreamURL); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case originURL == "":
+		if _, err := gitOutput(ctx, dir, "remote", "add", "origin", forkURL); err != nil {
+			return err
+		}
+	case !sameRemote(originURL, forkURL):
+		return fmt.Errorf("origin of %s points at %s, not the fork %s; not replacing it", dir, originURL, forkURL)
+	}
+
+	if pushURL != "" {
+		if _, err := gitOutput(ctx, dir, "remote", "set-url", "--push", "origin", pushURL); err != nil {
+			return err
+		}
+	}
+	if _, err := gitOutput(ctx, dir, "config", "remote.pushDefault", "origin"); err != nil {
+		return err
+	}
+	fmt.Printf("Configured %s: upstream %s, origin (fork) %s\n", dir, upstreamURL, forkURL)
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestForkURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		upstream    string
+		fork        string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "github HTTPS",
+			upstream: "https://github.com/owner/repo.git",
+			fork:     "me",
+			expected: "git@github.com:me/repo.git",
+		},
+		{
+			name:     "without .git suffix",
+			upstream: "https://github.com/uber-go/zap",
+			fork:     "me",
+			expected: "git@github.com:me/zap.git",
+		},
+		{
+			name:     "gitlab nested group",
+			upstream: "https://gitlab.com/group/subgroup/repo.git",
+			fork:     "me/forks",
+			expected: "git@gitlab.com:me/forks/repo.git",
+		},
+		{
+			name:     "SSH upstream",
+			upstream: "git@github.com:owner/repo.git",
+			fork:     "me",
+			expected: "git@github.com:me/repo.git",
+		},
+		{
+			name:        "no repository path",
+			upstream:    "https://github.com",
+			fork:        "me",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := forkURL(tt.upstream, tt.fork)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("forkURL(%q, %q) = %q, want %q", tt.upstream, tt.fork, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfigureFork(t *testing.T) {
+	ctx := context.Background()
+	const upstream = "https://github.com/owner/repo.git"
+	const fork = "git@github.com:me/repo.git"
+
+	tests := []struct {
+		name        string
+		remotes     [][2]string
+		push        string
+		expectError bool
+	}{
+		{
+			name:    "fresh clone with upstream remote",
+			remotes: [][2]string{{"upstream", upstream}},
+		},
+		{
+			name:    "existing clone of upstream over SSH",
+			remotes: [][2]string{{"origin", "git@github.com:owner/repo.git"}},
+		},
+		{
+			name:    "already configured",
+			remotes: [][2]string{{"origin", fork}, {"upstream", upstream}},
+		},
+		{
+			name:    "goget pushInsteadOf rule",
+			remotes: [][2]string{{"upstream", upstream}},
+			push:    "ssh://git@push.example.com/me/repo.git",
+		},
+		{
+			name:        "origin points elsewhere",
+			remotes:     [][2]string{{"origin", "https://github.com/someone/else.git"}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "repo")
+			initTestRepo(t, dir)
+			for _, remote := range tt.remotes {
+				if _, err := gitOutput(ctx, dir, "remote", "add", remote[0], remote[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := configureFork(ctx, dir, upstream, fork, tt.push)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			expected := map[string]string{
+				"remote.upstream.url":   upstream,
+				"remote.origin.url":     fork,
+				"remote.origin.pushurl": tt.push,
+				"remote.pushDefault":    "origin",
+			}
+			for key, want := range expected {
+				if got, _ := gitOutput(ctx, dir, "config", "--get", key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFixRemotesKeepsFork(t *testing.T) {
+	isolateGitConfig(t)
+	ctx := context.Background()
+	gopath := t.TempDir()
+	fake := &fakeCloner{gitInit: true, repos: map[string]map[string]string{
+		"https://github.com/owner/repo.git": {"go.mod": "module github.com/owner/repo\n"},
+	}}
+	opts := Options{Cloner: fake, HTTPS: true, Fork: "me"}
+	if _, err := runGoGet(ctx, "github.com/owner/repo", gopath, gopath, opts); err != nil {
+		t.Fatal(err)
+	}
+	opts.Fork, opts.FixRemotes = "", true
+	if _, err := runGoGet(ctx, "github.com/owner/repo", gopath, gopath, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(gopath, "src", "github.com", "owner", "repo")
+	expected := map[string]string{
+		"remote.origin.url":     "git@github.com:me/repo.git",
+		"remote.upstream.url":   "https://github.com/owner/repo.git",
+		"remote.origin-old.url": "",
+	}
+	for key, want := range expected {
+		if got, _ := gitOutput(ctx, dir, "config", "--get", key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
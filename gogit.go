@@ -0,0 +1,238 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// goGitCloner clones in process with go-git, for machines without a git
+// binary. It does not support partial clones or the mirror cache, and
+// ignores the module cache.
+type goGitCloner struct{}
+
+// Clone clones cmd.URL into dir. A sparse mode checks out only the sparse
+// paths, or everything if there are none.
+func (goGitCloner) Clone(ctx context.Context, cmd *GitCommand, dir string, opts Options) error {
+	if cmd.Mode.Filter != "" {
+		return fmt.Errorf("the %s backend does not support --filter", backendGoGit)
+	}
+	if opts.MirrorDir != "" {
+		return fmt.Errorf("the %s backend does not support the mirror cache", backendGoGit)
+	}
+
+	auth, err := goGitAuth(cmd.URL, opts)
+	if err != nil {
+		return err
+	}
+	cloneOpts := &git.CloneOptions{
+		URL:        cmd.URL,
+		Auth:       auth,
+		RemoteName: cmd.Origin,
+		Depth:      cmd.Mode.Depth,
+		NoCheckout: len(cmd.Mode.SparsePaths) > 0,
+	}
+	if cmd.Branch != "" {
+		cloneOpts.ReferenceName = plumbing.NewBranchReferenceName(cmd.Branch)
+	}
+	if opts.Submodules {
+		cloneOpts.RecurseSubmodules = git.DefaultSubmoduleRecursionDepth
+	}
+	repo, err := git.PlainCloneContext(ctx, dir, false, cloneOpts)
+	if err != nil {
+		return &GitError{Kind: classifyGoGitError(err), URL: cmd.URL, Args: []string{"clone", cmd.URL}, Err: err}
+	}
+	if cmd.originURL() != cmd.URL {
+		// Cloned from a mirror, or from where git's insteadOf rules send
+		// the URL; point the remote at the URL git would have kept
+		cfg, err := repo.Config()
+		if err != nil {
+			return err
+		}
+		remoteName := cmp.Or(cmd.Origin, git.DefaultRemoteName)
+		if remote, ok := cfg.Remotes[remoteName]; ok {
+			remote.URLs = []string{cmd.originURL()}
+		}
+		if err := repo.SetConfig(cfg); err != nil {
+			return err
+		}
+	}
+
+	if cloneOpts.NoCheckout {
+		head, err := repo.Head()
+		if err != nil {
+			return err
+		}
+		wt, err := repo.Worktree()
+		if err != nil {
+			return err
+		}
+		if err := wt.Checkout(&git.CheckoutOptions{Branch: head.Name(), SparseCheckoutDirectories: cmd.Mode.SparsePaths}); err != nil {
+			return fmt.Errorf("could not set up sparse checkout: %w", err)
+		}
+	}
+	return nil
+}
+
+// Fetch fetches every remote of the repository in dir
+func (goGitCloner) Fetch(ctx context.Context, dir string, opts Options) error {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return err
+	}
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return err
+	}
+	for _, remote := range remotes {
+		urls := remote.Config().URLs
+		if len(urls) == 0 {
+			continue
+		}
+		auth, err := goGitAuth(urls[0], opts)
+		if err != nil {
+			return err
+		}
+		err = remote.FetchContext(ctx, &git.FetchOptions{Auth: auth})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return &GitError{Kind: classifyGoGitError(err), URL: urls[0], Args: []string{"fetch", remote.Config().Name}, Err: err}
+		}
+	}
+	return nil
+}
+
+// Exists opens dir as a repository, without looking in parent directories
+func (goGitCloner) Exists(ctx context.Context, dir string) bool {
+	_, err := git.PlainOpen(dir)
+	return err == nil
+}
+
+// ListRemote lists the references of url with an in-memory remote
+func (goGitCloner) ListRemote(ctx context.Context, url string, opts Options) (RemoteInfo, error) {
+	auth, err := goGitAuth(url, opts)
+	if err != nil {
+		return RemoteInfo{}, err
+	}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}})
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return RemoteInfo{}, nil
+	}
+	if err != nil {
+		return RemoteInfo{}, &GitError{Kind: classifyGoGitError(err), URL: url, Args: []string{"ls-remote", url}, Err: err}
+	}
+	var info RemoteInfo
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+			info.DefaultBranch = ref.Target().Short()
+		} else if ref.Name().IsTag() {
+			info.Tags = append(info.Tags, ref.Name().Short())
+		}
+	}
+	slices.Sort(info.Tags)
+	return info, nil
+}
+
+// goGitAuth returns the go-git credentials for repoURL: for HTTPS URLs, the
+// token from the environment, if there is one; for SSH URLs, the
+// identity file goget's configuration names for it, the SSH agent, or else an
+// unencrypted default key in ~/.ssh, checking host keys against known_hosts
+// like the exec backend's ssh does. go-git does not run ssh, so the user's
+// GIT_SSH_COMMAND and ~/.ssh/config do not apply.
+func goGitAuth(repoURL string, opts Options) (transport.AuthMethod, error) {
+	if !isSSHURL(repoURL) {
+		if username, token := httpsToken(repoURL, opts); token != "" {
+			return &githttp.BasicAuth{Username: username, Password: token}, nil
+		}
+		return nil, nil
+	}
+	user := sshUser(repoURL)
+	callback, err := goGitHostKeyCallback(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if identity := opts.UserConfig.host(repoURL).IdentityFile; identity != "" {
+		// Like IdentitiesOnly: a deploy key must not lose out to the agent's keys
+		auth, err := gitssh.NewPublicKeysFromFile(user, identity, "")
+		if err != nil {
+			return nil, fmt.Errorf("could not load SSH identity for %s: %w", repoURL, err)
+		}
+		auth.HostKeyCallback = callback
+		return auth, nil
+	}
+	if os.Getenv("SSH_AUTH_SOCK") != "" {
+		if auth, err := gitssh.NewSSHAgentAuth(user); err == nil {
+			auth.HostKeyCallback = callback
+			return auth, nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		auth, err := gitssh.NewPublicKeysFromFile(user, filepath.Join(home, ".ssh", name), "")
+		if err == nil {
+			auth.HostKeyCallback = callback
+			return auth, nil
+		}
+	}
+	return nil, fmt.Errorf("no SSH agent or unencrypted key in ~/.ssh to clone %s (use --https)", repoURL)
+}
+
+// sshUser returns the user in an SSH URL like git@host:path, or "git"
+func sshUser(url string) string {
+	url = strings.TrimPrefix(url, "ssh://")
+	if i := strings.IndexAny(url, "@:/"); i != -1 && url[i] == '@' {
+		return url[:i]
+	}
+	return "git"
+}
+
+// goGitHostKeyCallback checks SSH host keys against their pinned
+// fingerprints, for hosts that have them, and otherwise against the user's
+// known_hosts files. With --accept-ssh-host, unknown hosts are accepted, but
+// changed keys are still rejected, like StrictHostKeyChecking=accept-new.
+func goGitHostKeyCallback(opts Options) (ssh.HostKeyCallback, error) {
+	knownHosts, knownHostsErr := gitssh.NewKnownHostsCallback()
+	if knownHostsErr != nil && !opts.AcceptSSHHost && opts.KnownHostsFile == "" {
+		return nil, fmt.Errorf("could not read known_hosts: %w", knownHostsErr)
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if opts.KnownHostsFile != "" {
+			if pinned, err := checkPinnedHostKey(hostname, key); pinned {
+				return err
+			}
+		}
+		if knownHostsErr != nil {
+			if opts.AcceptSSHHost {
+				// Without known_hosts, every host is new
+				return nil
+			}
+			return fmt.Errorf("could not read known_hosts: %w", knownHostsErr)
+		}
+		err := knownHosts(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if opts.AcceptSSHHost && errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return nil
+		}
+		return err
+	}, nil
+}
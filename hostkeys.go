@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// pinnedHostKeys holds the SSH host keys that the major forges publish:
+//
+//	https://docs.github.com/en/authentication/keeping-your-account-and-data-secure/githubs-ssh-key-fingerprints
+//	https://docs.gitlab.com/ee/user/gitlab_com/#ssh-host-keys-fingerprints
+//	https://support.atlassian.com/bitbucket-cloud/docs/configure-ssh-and-two-step-verification/
+//
+// Keys for these hosts are only trusted if they match. goget ships the keys
+// themselves, so it never has to fetch them over the network it distrusts.
+var pinnedHostKeys = map[string][]string{
+	"github.com": {
+		// SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl",
+		// SHA256:p2QAMXNIC1TJYWeIOttrVc98/R1BUFWu3/LiyKgUfQM
+		"ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBEmKSENjQEezOmxkZMy7opKgwFB9nkt5YRrYMjNuG5N87uRgg6CLrbo5wAdT/y6v0mKV0U2w0WZ2YB/++Tpockg=",
+		// SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s
+		"ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABgQCj7ndNxQowgcQnjshcLrqPEiiphnt+VTTvDP6mHBL9j1aNUkY4Ue1gvwnGLVlOhGeYrnZaMgRK6+PKCUXaDbC7qtbW8gIkhL7aGCsOr/C56SJMy/BCZfxd1nWzAOxSDPgVsmerOBYfNqltV9/hWCqBywINIR+5dIg6JTJ72pcEpEjcYgXkE2YEFXV1JHnsKgbLWNlhScqb2UmyRkQyytRLtL+38TGxkxCflmO+5Z8CSSNY7GidjMIZ7Q4zMjA2n1nGrlTDkzwDCsw+wqFPGQA179cnfGWOWRVruj16z6XyvxvjJwbz0wQZ75XK5tKSb7FNyeIEs4TT4jk+S4dhPeAUC5y+bDYirYgM4GC7uEnztnZyaVWQ7B381AK4Qdrwt51ZqExKbQpTUNn+EjqoTwvqNj4kqx5QUCI0ThS/YkOxJCXmPUWZbhjpCg56i+2aB6CmK2JGhn57K5mj0MNdBXA4/WnwH6XoPWJzK5Nyu2zB3nAZp+S5hpQs+p1vN1/wsjk=",
+	},
+	"gitlab.com": {
+		// SHA256:eUXGGm1YGsMAS7vkcx6JOJdOGHPem5gQp4taiCfCLB8
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAfuCHKVTjquxvt6CM6tdG4SLp1Btn/nOeHHE5UOzRdf",
+		// SHA256:HbW3g8zUjNSksFbqTiUWPWg2Bq1x8xdGUrliXFzSnUw
+		"ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBFSMqzJeV9rUzU4kWitGjeR4PWSa29SPqJ1fVkhtj3Hw9xjLVXVYrU9QlYWrOLXBpQ6KWjbjTDTdDkoohFzgbEY=",
+		// SHA256:ROQFvPThGrW4RuWLoL9tq9I9zJ42fK4XywyRtbOz/EQ
+		"ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQCsj2bNKTBSpIYDEGk9KxsGh3mySTRgMtXL583qmBpzeQ+jqCMRgBqB98u3z++J1sKlXHWfM9dyhSevkMwSbhoR8XIq/U0tCNyokEi/ueaBMCvbcTHhO7FcwzY92WK4Yt0aGROY5qX2UKSeOvuP4D6TPqKF1onrSzH9bx9XUf2lEdWT/ia1NEKjunUqu1xOB/StKDHMoX4/OKyIzuS0q/T1zOATthvasJFoPrAjkohTyaDUz2LN5JoH839hViyEG82yB+MjcFV5MU3N1l1QL3cVUCh93xSaua1N85qivl+siMkPGbO5xR/En4iEY6K2XPASUEMaieWVNTRCtJ4S8H+9",
+	},
+	"bitbucket.org": {
+		// SHA256:ybgmFkzwOSotHTHLJgHO0QN8L0xErw6vd0VhFA9m3SM
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIIazEu89wgQZ4bqs3d63QSMzYVa0MuJ2e2gKTKqu+UUO",
+		// SHA256:FC73VB6C4OQLSCrjEayhMp9UMxS97caD/Yyi2bhW/J0
+		"ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBPIQmuzMBuKdWeF4+a2sjSSpBK0iqitSQ+5BM9KhpexuGt20JpTVM7u5BDZngncgrqDMbWdxMWWOGtZ9UgbqgZE=",
+		// SHA256:46OSHA1Rmj8E8ERTC6xkNcmGOw9oFxYr0WF6zWW8l1E
+		"ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABgQDQeJzhupRu0u0cdegZIa8e86EG2qOCsIsD1Xw0xSeiPDlCr7kq97NLmMbpKTX6Esc30NuoqEEHCuc7yWtwp8dI76EEEB1VqY9QJq6vk+aySyboD5QF61I/1WeTwu+deCbgKMGbUijeXhtfbxSxm6JwGrXrhBdofTsbKRUsrN1WoNgUa8uqN1Vx6WAJw1JHPhglEGGHea6QICwJOAr/6mrui/oB7pkaWKHj3z7d1IC4KWLtY47elvjbaTlkN04Kc/5LFEirorGYVbt15kAUlqGM65pk6ZBxtaO3+30LVlORZkxOh+LKL/BvbZ/iRNhItLqNyieoQj/uh/7Iv4uyH/cV/0b4WDSd3DptigWq84lJubb9t/DnZlrJazxyDCulTmKdOR7vs9gMTo+uoIrPSb8ScTtvw65+odKAlBj59dhnVp9zd7QUojOpXlL62Aw56U4oO+FALuevvMjiWeavKhJqlR7i5n9srYcrNV7ttmDw7kf/97P5zauIhxcjX+xHv4M=",
+	},
+}
+
+// pinnedKeys parses the pinned keys of host
+func pinnedKeys(host string) ([]ssh.PublicKey, error) {
+	var keys []ssh.PublicKey
+	for _, line := range pinnedHostKeys[host] {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("pinned host key for %s: %w", host, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// pinnedHost returns the host of repoURL if its host keys are pinned, or ""
+func pinnedHost(repoURL string) string {
+	if !isSSHURL(repoURL) || sshPort(repoURL) != "" {
+		return ""
+	}
+	host := strings.ToLower(extractHostFromGitURL(repoURL))
+	if _, ok := pinnedHostKeys[host]; !ok {
+		return ""
+	}
+	return host
+}
+
+// knownHostsPath returns the known_hosts file goget keeps the pinned host
+// keys in
+func knownHostsPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "goget", "known_hosts"), nil
+}
+
+// knownHostsData returns the known_hosts lines for every pinned key
+func knownHostsData() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, host := range slices.Sorted(maps.Keys(pinnedHostKeys)) {
+		keys, err := pinnedKeys(host)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			fmt.Fprintln(&buf, knownhosts.Line([]string{host}, key))
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// sshConfig returns the configuration ssh would use to connect to host, as
+// printed by ssh -G. Tests replace it to avoid the user's ~/.ssh/config.
+var sshConfig = func(ctx context.Context, host string) ([]byte, error) {
+	return exec.CommandContext(ctx, "ssh", "-G", host).Output()
+}
+
+// sshConfigRedirects reports whether the user's ssh configuration connects to
+// host somewhere other than host on port 22, like GitHub's ssh.github.com on
+// port 443, or checks its keys under another name. ssh then looks the keys
+// up under a name goget's known_hosts file does not have.
+func sshConfigRedirects(ctx context.Context, host string) bool {
+	out, err := sshConfig(ctx, host)
+	if err != nil {
+		// Without ssh -G, assume the defaults
+		return false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		switch strings.ToLower(key) {
+		case "hostname":
+			if !strings.EqualFold(value, host) {
+				return true
+			}
+		case "port":
+			if value != "22" {
+				return true
+			}
+		case "hostkeyalias":
+			if value != "none" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	knownHostsMu        sync.Mutex
+	knownHostsErrs      = make(map[string]error) // file -> result of writeKnownHosts
+	knownHostsRedirects = make(map[string]bool)  // host -> result of sshConfigRedirects
+)
+
+// writeKnownHosts makes sure the known_hosts file at path holds exactly the
+// pinned keys, once per run
+func writeKnownHosts(path string) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+	if err, ok := knownHostsErrs[path]; ok {
+		return err
+	}
+
+	err := func() error {
+		data, err := knownHostsData()
+		if err != nil {
+			return err
+		}
+		if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		// Replace the file in one step, so a concurrent goget's ssh never
+		// reads half of it
+		tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		if _, err := tmp.Write(data); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		return os.Rename(tmp.Name(), path)
+	}()
+	knownHostsErrs[path] = err
+	return err
+}
+
+// pinnedSSHOptions returns the ssh options that check the host keys of the
+// pinned host of repoURL against goget's known_hosts file, and reports
+// whether the host is pinned. A host the user's ssh configuration sends
+// elsewhere is not pinned, and the user's known_hosts apply. If the file
+// can't be written, the options are empty too, but a pinned host is still
+// never trusted on first use.
+func pinnedSSHOptions(ctx context.Context, repoURL string, opts Options) (options string, pinned bool) {
+	host := pinnedHost(repoURL)
+	if host == "" || opts.KnownHostsFile == "" || opts.Offline {
+		// --offline clones from the mirror cache, so ssh never runs
+		return "", false
+	}
+	knownHostsMu.Lock()
+	redirects, ok := knownHostsRedirects[host]
+	if !ok {
+		redirects = sshConfigRedirects(ctx, host)
+		knownHostsRedirects[host] = redirects
+	}
+	knownHostsMu.Unlock()
+	if redirects {
+		return "", false
+	}
+	if err := writeKnownHosts(opts.KnownHostsFile); err != nil {
+		log.Printf("WARN: could not write %s: %v; checking the host keys of %s against your own known_hosts files", opts.KnownHostsFile, err, host)
+		return "", true
+	}
+	return " -o UserKnownHostsFile=" + shellQuote(opts.KnownHostsFile) + " -o StrictHostKeyChecking=yes", true
+}
+
+// checkPinnedHostKey rejects a host key for a pinned host that is not one of
+// its published keys. It reports whether hostname is pinned.
+func checkPinnedHostKey(hostname string, key ssh.PublicKey) (pinned bool, err error) {
+	host, port, _ := strings.Cut(hostname, ":")
+	host = strings.ToLower(host)
+	if _, ok := pinnedHostKeys[host]; !ok || (port != "" && port != "22") {
+		return false, nil
+	}
+	keys, err := pinnedKeys(host)
+	if err != nil {
+		return true, err
+	}
+	for _, pin := range keys {
+		if bytes.Equal(pin.Marshal(), key.Marshal()) {
+			return true, nil
+		}
+	}
+	return true, fmt.Errorf("%w: %s offered host key %s, which is not one of its published keys", ErrHostKeyChanged, host, ssh.FingerprintSHA256(key))
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// newHostKey returns a fresh ed25519 public key
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// pinTestKeys pins github.com to good for the duration of the test, with an
+// ssh configuration that connects to each host directly
+func pinTestKeys(t *testing.T, good ssh.PublicKey) {
+	oldPins, oldConfig := pinnedHostKeys, sshConfig
+	pinnedHostKeys = map[string][]string{"github.com": {strings.TrimSpace(string(ssh.MarshalAuthorizedKey(good)))}}
+	sshConfig = func(ctx context.Context, host string) ([]byte, error) {
+		return []byte("user git\nhostname " + host + "\nport 22\n"), nil
+	}
+	resetKnownHosts := func() {
+		knownHostsMu.Lock()
+		clear(knownHostsErrs)
+		clear(knownHostsRedirects)
+		knownHostsMu.Unlock()
+	}
+	resetKnownHosts()
+	t.Cleanup(func() {
+		pinnedHostKeys, sshConfig = oldPins, oldConfig
+		resetKnownHosts()
+	})
+}
+
+func TestPinnedHostKeys(t *testing.T) {
+	// The fingerprints the forges publish, to catch a mistyped key
+	published := map[string][]string{
+		"github.com": {
+			"SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU",
+			"SHA256:p2QAMXNIC1TJYWeIOttrVc98/R1BUFWu3/LiyKgUfQM",
+			"SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s",
+		},
+		"gitlab.com": {
+			"SHA256:eUXGGm1YGsMAS7vkcx6JOJdOGHPem5gQp4taiCfCLB8",
+			"SHA256:HbW3g8zUjNSksFbqTiUWPWg2Bq1x8xdGUrliXFzSnUw",
+			"SHA256:ROQFvPThGrW4RuWLoL9tq9I9zJ42fK4XywyRtbOz/EQ",
+		},
+		"bitbucket.org": {
+			"SHA256:ybgmFkzwOSotHTHLJgHO0QN8L0xErw6vd0VhFA9m3SM",
+			"SHA256:FC73VB6C4OQLSCrjEayhMp9UMxS97caD/Yyi2bhW/J0",
+			"SHA256:46OSHA1Rmj8E8ERTC6xkNcmGOw9oFxYr0WF6zWW8l1E",
+		},
+	}
+	if len(pinnedHostKeys) != len(published) {
+		t.Errorf("%d pinned hosts, want %d", len(pinnedHostKeys), len(published))
+	}
+	for host, fingerprints := range published {
+		keys, err := pinnedKeys(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, key := range keys {
+			got = append(got, ssh.FingerprintSHA256(key))
+		}
+		if !slices.Equal(got, fingerprints) {
+			t.Errorf("%s keys have fingerprints %v, want %v", host, got, fingerprints)
+		}
+	}
+}
+
+func TestPinnedHost(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"git@github.com:user/repo.git", "github.com"},
+		{"ssh://git@GitHub.com/user/repo.git", "github.com"},
+		{"git@gitlab.com:user/repo.git", "gitlab.com"},
+		{"git@bitbucket.org:user/repo.git", "bitbucket.org"},
+		{"https://github.com/user/repo.git", ""},
+		{"ssh://git@github.com:2222/user/repo.git", ""},
+		{"git@git.example.com:user/repo.git", ""},
+	}
+	for _, tt := range tests {
+		if got := pinnedHost(tt.url); got != tt.expected {
+			t.Errorf("pinnedHost(%q) = %q, want %q", tt.url, got, tt.expected)
+		}
+	}
+}
+
+func TestWriteKnownHosts(t *testing.T) {
+	good := newHostKey(t)
+	pinTestKeys(t, good)
+	path := filepath.Join(t.TempDir(), "goget", "known_hosts")
+	// A key the pins no longer list is dropped
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte(knownhosts.Line([]string{"github.com"}, newHostKey(t))+"\n"), 0644)
+
+	if err := writeKnownHosts(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := knownhosts.Line([]string{"github.com"}, good) + "\n"; string(data) != want {
+		t.Errorf("known_hosts = %q, want only the pinned key %q", data, want)
+	}
+}
+
+func TestSSHCommandPinned(t *testing.T) {
+	isolateGitConfig(t)
+	good := newHostKey(t)
+	tests := []struct {
+		name     string
+		config   string // ssh -G output; "" connects directly
+		url      string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "pinned host",
+			url:      "git@github.com:user/repo.git",
+			contains: []string{"-o UserKnownHostsFile=", "-o StrictHostKeyChecking=yes"},
+			excludes: []string{"accept-new"},
+		},
+		{
+			name:     "ssh config sends the host to another port",
+			config:   "hostname ssh.github.com\nport 443\n",
+			url:      "git@github.com:user/repo.git",
+			contains: []string{"-o StrictHostKeyChecking=accept-new"},
+			excludes: []string{"UserKnownHostsFile"},
+		},
+		{
+			name:     "ssh config checks the keys under an alias",
+			config:   "hostname github.com\nport 22\nhostkeyalias gh\n",
+			url:      "git@github.com:user/repo.git",
+			contains: []string{"-o StrictHostKeyChecking=accept-new"},
+			excludes: []string{"UserKnownHostsFile"},
+		},
+		{
+			name:     "other host",
+			url:      "git@git.example.com:user/repo.git",
+			contains: []string{"-o StrictHostKeyChecking=accept-new"},
+			excludes: []string{"UserKnownHostsFile"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pinTestKeys(t, good)
+			if tt.config != "" {
+				sshConfig = func(ctx context.Context, host string) ([]byte, error) {
+					return []byte(tt.config), nil
+				}
+			}
+			opts := Options{AcceptSSHHost: true, KnownHostsFile: filepath.Join(t.TempDir(), "known_hosts")}
+			got := sshCommand(context.Background(), "", tt.url, opts)
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("sshCommand() = %q, want it to contain %q", got, want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(got, unwanted) {
+					t.Errorf("sshCommand() = %q, want it not to contain %q", got, unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestGoGitHostKeyCallbackPinned(t *testing.T) {
+	good := newHostKey(t)
+	pinTestKeys(t, good)
+	t.Setenv("HOME", t.TempDir()) // no known_hosts
+	t.Setenv("SSH_KNOWN_HOSTS", "")
+
+	callback, err := goGitHostKeyCallback(Options{AcceptSSHHost: true, KnownHostsFile: "unused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := callback("github.com:22", nil, good); err != nil {
+		t.Errorf("pinned key rejected: %v", err)
+	}
+	err = callback("github.com:22", nil, newHostKey(t))
+	if !errors.Is(err, ErrHostKeyChanged) || classifyGoGitError(err) != ErrHostKeyChanged {
+		t.Errorf("mismatched key: error = %v, want ErrHostKeyChanged", err)
+	}
+	if err := callback("git.example.com:22", nil, newHostKey(t)); err != nil {
+		t.Errorf("new key for an unpinned host rejected with --accept-ssh-host: %v", err)
+	}
+}
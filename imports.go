@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// isStandardImport reports whether an import path belongs to the standard
+// library (or cgo), which never needs to be fetched. Like the go command, we
+// treat any path whose first element has no dot as a standard import.
+func isStandardImport(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+// collectImports parses the Go files in dir with go/parser in ImportsOnly mode
+// and returns the non-standard imports they contain, sorted. Files excluded by
+// build constraints in ctxt and _test.go files are ignored. If recursive is
+// true, subdirectories are scanned too, skipping testdata, vendor and
+// directories whose names start with "." or "_", as the go command does.
+func collectImports(ctxt *build.Context, dir string, recursive bool) ([]string, error) {
+	seen := make(map[string]bool)
+	fset := token.NewFileSet()
+
+	scanDir := func(dir string) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			if ok, err := ctxt.MatchFile(dir, name); err != nil || !ok {
+				continue
+			}
+			file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ImportsOnly)
+			if err != nil {
+				return fmt.Errorf("could not parse %s: %w", filepath.Join(dir, name), err)
+			}
+			for _, spec := range file.Imports {
+				path, err := strconv.Unquote(spec.Path.Value)
+				if err != nil || isStandardImport(path) {
+					continue
+				}
+				seen[path] = true
+			}
+		}
+		return nil
+	}
+
+	if !recursive {
+		if err := scanDir(dir); err != nil {
+			return nil, err
+		}
+	} else {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			name := d.Name()
+			if path != dir && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return scanDir(path)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	imports := make([]string, 0, len(seen))
+	for path := range seen {
+		imports = append(imports, path)
+	}
+	slices.Sort(imports)
+	return imports, nil
+}
+
+// fetchImports scans the Go files in dir (and its subdirectories, if
+// recursive is true) and fetches every import that is not already present in
+// GOPATH, then repeats the process for the packages it fetched, like
+// "go get -d" did in GOPATH mode. Packages that are already present are
+// scanned too, so their missing imports are fetched as well.
+func fetchImports(ctx context.Context, dir string, recursive bool, gopath, workingDir string, opts Options) ([]DependencyResult, error) {
+	ctxt := build.Default
+	ctxt.BuildTags = opts.Tags
+
+	// Imports of the scanned tree itself are not dependencies
+	self, err := declaredImportPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	isSelf := func(path string) bool {
+		return self != "" && (path == self || strings.HasPrefix(path, self+"/"))
+	}
+
+	srcDir := filepath.Join(firstGOPATH(gopath), "src")
+	visited := make(map[string]bool)
+	var results []DependencyResult
+
+	pending, err := collectImports(&ctxt, dir, recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	for len(pending) > 0 {
+		// Work through the import graph one level at a time, cloning each
+		// level's missing packages in parallel
+		var missing, present []string
+		for _, path := range pending {
+			if visited[path] || isSelf(path) {
+				continue
+			}
+			visited[path] = true
+			if _, err := os.Stat(filepath.Join(srcDir, filepath.FromSlash(path))); err == nil {
+				present = append(present, path)
+			} else {
+				missing = append(missing, path)
+			}
+		}
+
+		if len(missing) > 0 {
+			fmt.Printf("Fetching %d missing imports...\n", len(missing))
+			for _, result := range runGoGetParallel(ctx, missing, gopath, workingDir, opts) {
+				results = append(results, result)
+				if result.Error == nil {
+					present = append(present, result.ImportPath)
+				}
+			}
+		}
+
+		pending = nil
+		for _, path := range present {
+			imports, err := collectImports(&ctxt, filepath.Join(srcDir, filepath.FromSlash(path)), false)
+			if err != nil {
+				// The package may not exist in the repository we cloned;
+				// there is nothing more to follow from it
+				continue
+			}
+			pending = append(pending, imports...)
+		}
+	}
+
+	return results, nil
+}
+
+// firstGOPATH returns the first entry of a GOPATH list, which is where goget
+// clones repositories.
+func firstGOPATH(gopath string) string {
+	if before, _, ok := strings.Cut(gopath, string(os.PathListSeparator)); ok {
+		return before
+	}
+	return gopath
+}
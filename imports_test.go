@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"go/build"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles creates files (relative slash paths) with the given contents
+// under dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIsStandardImport(t *testing.T) {
+	tests := []struct {
+		importPath string
+		expected   bool
+	}{
+		{"fmt", true},
+		{"net/http", true},
+		{"C", true},
+		{"github.com/user/repo", false},
+		{"golang.org/x/net/html", false},
+	}
+
+	for _, tt := range tests {
+		if got := isStandardImport(tt.importPath); got != tt.expected {
+			t.Errorf("isStandardImport(%q) = %v, want %v", tt.importPath, got, tt.expected)
+		}
+	}
+}
+
+func TestCollectImports(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"github.com/user/a"
+)
+`,
+		"main_test.go":            "package main\n\nimport _ \"github.com/user/testonly\"\n",
+		"tagged.go":               "//go:build sometag\n\npackage main\n\nimport _ \"github.com/user/tagged\"\n",
+		"sub/sub.go":              "package sub\n\nimport _ \"example.com/b\"\n",
+		"testdata/td.go":          "package td\n\nimport _ \"github.com/user/testdata\"\n",
+		"vendor/v/v.go":           "package v\n\nimport _ \"github.com/user/vendored\"\n",
+		"_ignored/ignored.go":     "package ignored\n\nimport _ \"github.com/user/ignored\"\n",
+		"sub/other_plan9.go":      "package sub\n\nimport _ \"github.com/user/plan9only\"\n",
+		"sub/deeper/deeper.go":    "package deeper\n\nimport _ \"github.com/user/a/sub\"\n",
+		"sub/deeper/notgo/README": "not go",
+	})
+
+	ctxt := build.Default
+	ctxt.GOOS = "linux"
+
+	tests := []struct {
+		name      string
+		recursive bool
+		tags      []string
+		expected  []string
+	}{
+		{
+			name:     "single directory",
+			expected: []string{"github.com/user/a"},
+		},
+		{
+			name:      "recursive",
+			recursive: true,
+			expected:  []string{"example.com/b", "github.com/user/a", "github.com/user/a/sub"},
+		},
+		{
+			name:     "build tags",
+			tags:     []string{"sometag"},
+			expected: []string{"github.com/user/a", "github.com/user/tagged"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctxt.BuildTags = tt.tags
+			got, err := collectImports(&ctxt, dir, tt.recursive)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("collectImports() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFetchImportsAllPresent(t *testing.T) {
+	// Every import is already in GOPATH, so nothing should be cloned, but
+	// the packages in GOPATH are still followed.
+	gopath := t.TempDir()
+	writeFiles(t, gopath, map[string]string{
+		"src/github.com/user/a/a.go": "package a\n\nimport _ \"github.com/user/b\"\n",
+		"src/github.com/user/b/b.go": "package b\n\nimport _ \"github.com/user/app/internal\"\n",
+	})
+
+	project := t.TempDir()
+	writeFiles(t, project, map[string]string{
+		"go.mod":               "module github.com/user/app\n",
+		"main.go":              "package main\n\nimport _ \"github.com/user/a\"\n",
+		"internal/internal.go": "package internal\n\nimport _ \"github.com/user/app/other\"\n",
+	})
+
+	results, err := fetchImports(context.Background(), project, true, gopath, project, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no fetches, got %+v", results)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// repoSource describes a repository the user pointed at directly, by pasting
+// a clone URL or a browser URL instead of an import path.
+type repoSource struct {
+	URL    string // clone URL, using the protocol the user chose
+	Root   string // import path of the repository root
+	Branch string // branch to check out, from a browser URL
+	Subdir string // directory inside the repository, from a browser URL
+}
+
+// scpLikeURL matches the scp-style syntax git accepts for SSH remotes, e.g.
+// git@github.com:user/repo.git
+var scpLikeURL = regexp.MustCompile(`^(?:[\w.-]+@)?([\w.-]+\.[\w.-]+):(.+)$`)
+
+// normalizeInput turns the argument a user passed into an import path. Plain
+// import paths are returned unchanged. Clone URLs (https://, ssh://, git@...)
+// and browser URLs for common Git hosts are converted into the import path of
+// the repository, and a non-nil repoSource records the URL to clone from.
+// pkg.go.dev URLs are converted into the import path they document, with any
+// version in the URL as an @version suffix.
+func normalizeInput(arg string) (importPath string, src *repoSource, err error) {
+	if !strings.Contains(arg, "://") {
+		if m := scpLikeURL.FindStringSubmatch(arg); m != nil {
+			root, err := joinRoot(m[1], m[2])
+			if err != nil {
+				return "", nil, fmt.Errorf("could not parse git URL %q: %v", arg, err)
+			}
+			return root, &repoSource{URL: arg, Root: root}, nil
+		}
+		return arg, nil, nil
+	}
+
+	u, err := url.Parse(arg)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not parse URL %q: %v", arg, err)
+	}
+
+	switch u.Scheme {
+	case "https", "http", "ssh", "git", "git+ssh":
+	default:
+		return "", nil, fmt.Errorf("unsupported URL scheme %q in %q", u.Scheme, arg)
+	}
+
+	host := u.Hostname()
+	if host == "pkg.go.dev" || host == "godoc.org" {
+		return docsImportPath(u.Path)
+	}
+
+	if u.Scheme == "https" || u.Scheme == "http" {
+		if src := parseBrowserURL(u); src != nil {
+			if src.Subdir != "" {
+				return src.Root + "/" + src.Subdir, src, nil
+			}
+			return src.Root, src, nil
+		}
+	}
+
+	root, err := joinRoot(host, u.Path)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not parse URL %q: %v", arg, err)
+	}
+	return root, &repoSource{URL: arg, Root: root}, nil
+}
+
+// joinRoot builds an import path from a host and a repository path, trimming
+// the slashes and .git suffix that appear in clone URLs.
+func joinRoot(host, path string) (string, error) {
+	path = strings.Trim(path, "/")
+	path = strings.TrimSuffix(path, ".git")
+	path = strings.TrimPrefix(path, "~")
+	if host == "" || path == "" {
+		return "", fmt.Errorf("missing host or repository path")
+	}
+	return host + "/" + path, nil
+}
+
+// docsImportPath extracts the import path from the path of a pkg.go.dev URL,
+// e.g. /github.com/user/repo@v1.2.3/sub. A version in the URL is moved to the
+// end, giving the path@version form "go get" accepts.
+func docsImportPath(path string) (string, *repoSource, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	var version string
+	for i, part := range parts {
+		if before, after, ok := strings.Cut(part, "@"); ok {
+			parts[i] = before
+			version = after
+		}
+	}
+	importPath := strings.Join(parts, "/")
+	if importPath == "" {
+		return "", nil, fmt.Errorf("no import path in documentation URL %q", path)
+	}
+	if version != "" {
+		importPath += "@" + version
+	}
+	return importPath, nil, nil
+}
+
+// parseBrowserURL recognizes the web UI URLs of the common Git hosts, for
+// example https://github.com/user/repo/tree/main/sub/pkg. It returns nil for
+// any other URL.
+func parseBrowserURL(u *url.URL) *repoSource {
+	host := u.Hostname()
+	if !isCommonGitHost(host) {
+		return nil
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	parts = slices.DeleteFunc(parts, func(s string) bool { return s == "" })
+	if len(parts) < 2 {
+		return nil
+	}
+
+	// GitLab allows nested groups and separates the repository path from
+	// the browser path with a "-" element.
+	repoParts := parts[:2]
+	rest := parts[2:]
+	if host == "gitlab.com" {
+		if idx := slices.Index(parts, "-"); idx >= 2 {
+			repoParts = parts[:idx]
+			rest = parts[idx+1:]
+		} else {
+			repoParts = parts
+			rest = nil
+		}
+	}
+	repoParts[len(repoParts)-1] = strings.TrimSuffix(repoParts[len(repoParts)-1], ".git")
+	repo := strings.Join(repoParts, "/")
+
+	src := &repoSource{
+		URL:  fmt.Sprintf("%s://%s/%s.git", u.Scheme, u.Host, repo),
+		Root: host + "/" + repo,
+	}
+
+	// tree/<branch>/<dir> on GitHub and GitLab, src/<branch>/<dir> on
+	// Bitbucket. For blob URLs the last element names a file, so the
+	// directory is its parent.
+	if len(rest) >= 2 && (rest[0] == "tree" || rest[0] == "blob" || rest[0] == "src") {
+		src.Branch = rest[1]
+		dir := rest[2:]
+		if rest[0] == "blob" && len(dir) > 0 {
+			dir = dir[:len(dir)-1]
+		}
+		src.Subdir = strings.Join(dir, "/")
+	}
+	return src
+}
+
+// isSSHURL reports whether a git URL will be fetched over SSH.
+func isSSHURL(gitURL string) bool {
+	if strings.HasPrefix(gitURL, "ssh://") || strings.HasPrefix(gitURL, "git+ssh://") {
+		return true
+	}
+	return !strings.Contains(gitURL, "://") && scpLikeURL.MatchString(gitURL)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNormalizeInput(t *testing.T) {
+	tests := []struct {
+		name         string
+		arg          string
+		expectedPath string
+		expectedSrc  *repoSource
+		expectError  bool
+	}{
+		{
+			name:         "plain import path",
+			arg:          "github.com/user/repo",
+			expectedPath: "github.com/user/repo",
+		},
+		{
+			name:         "import path with ellipsis",
+			arg:          "github.com/user/repo/...",
+			expectedPath: "github.com/user/repo/...",
+		},
+		{
+			name:         "relative path",
+			arg:          "./repo",
+			expectedPath: "./repo",
+		},
+		{
+			name:         "github HTTPS URL",
+			arg:          "https://github.com/user/repo",
+			expectedPath: "github.com/user/repo",
+			expectedSrc: &repoSource{
+				URL:  "https://github.com/user/repo.git",
+				Root: "github.com/user/repo",
+			},
+		},
+		{
+			name:         "github SSH URL",
+			arg:          "git@github.com:user/repo.git",
+			expectedPath: "github.com/user/repo",
+			expectedSrc: &repoSource{
+				URL:  "git@github.com:user/repo.git",
+				Root: "github.com/user/repo",
+			},
+		},
+		{
+			name:         "ssh URL with port",
+			arg:          "ssh://git@host.example.com:2222/team/repo.git",
+			expectedPath: "host.example.com/team/repo",
+			expectedSrc: &repoSource{
+				URL:  "ssh://git@host.example.com:2222/team/repo.git",
+				Root: "host.example.com/team/repo",
+			},
+		},
+		{
+			name:         "github tree URL",
+			arg:          "https://github.com/user/repo/tree/main/sub/pkg",
+			expectedPath: "github.com/user/repo/sub/pkg",
+			expectedSrc: &repoSource{
+				URL:    "https://github.com/user/repo.git",
+				Root:   "github.com/user/repo",
+				Branch: "main",
+				Subdir: "sub/pkg",
+			},
+		},
+		{
+			name:         "github blob URL",
+			arg:          "https://github.com/user/repo/blob/dev/sub/pkg/file.go",
+			expectedPath: "github.com/user/repo/sub/pkg",
+			expectedSrc: &repoSource{
+				URL:    "https://github.com/user/repo.git",
+				Root:   "github.com/user/repo",
+				Branch: "dev",
+				Subdir: "sub/pkg",
+			},
+		},
+		{
+			name:         "gitlab tree URL with nested group",
+			arg:          "https://gitlab.com/group/subgroup/repo/-/tree/main/pkg",
+			expectedPath: "gitlab.com/group/subgroup/repo/pkg",
+			expectedSrc: &repoSource{
+				URL:    "https://gitlab.com/group/subgroup/repo.git",
+				Root:   "gitlab.com/group/subgroup/repo",
+				Branch: "main",
+				Subdir: "pkg",
+			},
+		},
+		{
+			name:         "bitbucket src URL",
+			arg:          "https://bitbucket.org/user/repo/src/master/pkg/",
+			expectedPath: "bitbucket.org/user/repo/pkg",
+			expectedSrc: &repoSource{
+				URL:    "https://bitbucket.org/user/repo.git",
+				Root:   "bitbucket.org/user/repo",
+				Branch: "master",
+				Subdir: "pkg",
+			},
+		},
+		{
+			name:         "custom domain HTTPS clone URL",
+			arg:          "https://git.example.com/team/repo.git",
+			expectedPath: "git.example.com/team/repo",
+			expectedSrc: &repoSource{
+				URL:  "https://git.example.com/team/repo.git",
+				Root: "git.example.com/team/repo",
+			},
+		},
+		{
+			name:         "pkg.go.dev URL",
+			arg:          "https://pkg.go.dev/github.com/user/repo/sub@v1.2.3",
+			expectedPath: "github.com/user/repo/sub@v1.2.3",
+		},
+		{
+			name:         "pkg.go.dev URL with version on module",
+			arg:          "https://pkg.go.dev/golang.org/x/net@v0.20.0/html#section",
+			expectedPath: "golang.org/x/net/html@v0.20.0",
+		},
+		{
+			name:         "pkg.go.dev URL without version",
+			arg:          "https://pkg.go.dev/github.com/user/repo",
+			expectedPath: "github.com/user/repo",
+		},
+		{
+			name:        "unsupported scheme",
+			arg:         "ftp://example.com/repo",
+			expectError: true,
+		},
+		{
+			name:        "URL without a path",
+			arg:         "https://example.com",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, src, err := normalizeInput(tt.arg)
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if path != tt.expectedPath {
+				t.Errorf("path = %q, want %q", path, tt.expectedPath)
+			}
+
+			if (src == nil) != (tt.expectedSrc == nil) {
+				t.Fatalf("src = %+v, want %+v", src, tt.expectedSrc)
+			}
+			if src != nil && *src != *tt.expectedSrc {
+				t.Errorf("src = %+v, want %+v", *src, *tt.expectedSrc)
+			}
+		})
+	}
+}
+
+func TestIsSSHURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"git@github.com:user/repo.git", true},
+		{"ssh://git@host.example.com:2222/team/repo.git", true},
+		{"https://github.com/user/repo.git", false},
+		{"https://go.googlesource.com/sync", false},
+		{"/tmp/repo.git", false},
+	}
+
+	for _, tt := range tests {
+		if got := isSSHURL(tt.url); got != tt.expected {
+			t.Errorf("isSSHURL(%q) = %v, want %v", tt.url, got, tt.expected)
+		}
+	}
+}
+
+func TestExtractHostFromGitURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"git@github.com:user/repo.git", "github.com"},
+		{"ssh://git@host.example.com:2222/team/repo.git", "host.example.com"},
+		{"https://gitlab.com/user/repo.git", "gitlab.com"},
+	}
+
+	for _, tt := range tests {
+		if got := extractHostFromGitURL(tt.url); got != tt.expected {
+			t.Errorf("extractHostFromGitURL(%q) = %q, want %q", tt.url, got, tt.expected)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"cmp"
 	"context"
 	"flag"
 	"fmt"
@@ -11,10 +12,12 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"slices"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/html"
@@ -25,6 +28,30 @@ var httpsFlag = flag.Bool("https", false, "use HTTPS for git clones instead of S
 var modFlag = flag.String("mod", "", "path to go.mod file to fetch all direct dependencies")
 var acceptSSHHostFlag = flag.Bool("accept-ssh-host", false, "automatically accept new SSH host keys (use with caution)")
 var skipFsckFlag = flag.Bool("skip-fsck", false, "skip fsck checks during clone (allows cloning repos with fsck errors in packed objects)")
+var depsFlag = flag.Bool("deps", false, "also fetch missing imports of the package (and its subpackages with /...), recursively; with no path, scan the current directory")
+var tagsFlag = flag.String("tags", "", "comma-separated build tags to honor when scanning imports with --deps")
+var layoutFlag = flag.String("layout", layoutGOPATH, `checkout layout: "gopath", or "versioned" to also check out path@version worktrees`)
+var updateFlag = flag.Bool("u", false, "update existing clones: fetch and fast-forward clean branches that track a remote")
+var fixRemotesFlag = flag.Bool("fix-remotes", false, "rewrite the origin of existing clones that points somewhere other than the resolved URL, keeping the old URL as origin-old")
+var forkFlag = flag.String("fork", "", "clone upstream as the \"upstream\" remote over HTTPS and add your fork, owned by this user or namespace, as \"origin\" over SSH")
+var adoptFlag = flag.Bool("adopt", false, "turn existing directories that are not git checkouts into clones instead of failing")
+var depthFlag = flag.Int("depth", 0, "make shallow clones with this many commits of history")
+var filterFlag = flag.String("filter", "", `make partial clones: "blob:none" fetches file contents on demand, "tree:0" also directories`)
+var sparseFlag = flag.Bool("sparse", false, "check out only the top-level files and the requested subpackage")
+var unshallowFlag = flag.String("unshallow", "", "convert the shallow, partial or sparse clone of this import path to a full clone")
+var mirrorFlag = flag.Bool("mirror", false, "keep bare mirrors of cloned repositories in the user cache directory and clone with them as a reference")
+var dissociateFlag = flag.Bool("dissociate", false, "with --mirror, copy objects from the mirror so clones do not depend on it")
+var offlineFlag = flag.Bool("offline", false, "clone only from the mirror cache, without using the network")
+var modCacheFlag = flag.Bool("modcache", true, "reuse objects from the go command's module cache (GOMODCACHE/cache/vcs) when cloning")
+var submodulesFlag = flag.Bool("submodules", false, "also check out submodules, recursively, over the same protocol as the repository")
+var lfsFlag = flag.String("lfs", "", `Git LFS handling: "skip" leaves pointer files, "pull" downloads LFS files after cloning`)
+var backendFlag = flag.String("backend", backendExec, `clone backend: "exec" runs the git binary, "go-git" clones in process without one`)
+var maxAttemptsFlag = flag.Int("max-attempts", defaultMaxAttempts, "attempts for each clone and go-import lookup that fails with a network or server error; 1 disables retries")
+var retryDeadlineFlag = flag.Duration("retry-deadline", defaultRetryDeadline, "stop retrying a clone or go-import lookup this long after its first attempt; 0 means no limit")
+var pinHostKeysFlag = flag.Bool("pin-host-keys", true, "check the SSH host keys of github.com, gitlab.com and bitbucket.org against their published fingerprints")
+var configFlag = flag.String("config", "", "path to goget's JSON configuration file (default: goget/config.json in the user config directory)")
+var checkFlag = flag.Bool("check", false, "only check that each repository can be reached, with git ls-remote, without cloning anything")
+var canonicalFlag = flag.Bool("canonical", false, "move clones whose go.mod module path differs from the clone location to the declared path")
 
 // Config holds the configuration for a goget operation
 type Config struct {
@@ -32,6 +59,90 @@ type Config struct {
 	WorkingDir  string
 	ImportPath  string
 	HasEllipsis bool
+	Version     string // module version from a path@version argument
+
+	// Set when the user passed a URL instead of an import path
+	RepoURL  string // clone URL to use instead of calling getRepositoryURL
+	RepoRoot string // import path of the repository root
+	Branch   string // branch to check out
+
+	Client HTTPClient // for go-import discovery; nil means a default client
+	// Imports is the mirror cache's record of the repositories vanity
+	// import paths resolve to; nil without a mirror cache
+	Imports *importCache
+	Offline bool // look vanity import paths up in Imports instead of discovering them
+}
+
+// resolveRepository is resolveRepository with c's settings. With Offline,
+// vanity import paths are looked up in the mirror cache instead of being
+// discovered, since discovery needs the network; otherwise the repositories
+// they resolve to are recorded there for later offline runs.
+func (c *Config) resolveRepository(importPath string, useHTTPS bool) (root, repoURL string) {
+	if !shouldUseDiscovery(importPath) {
+		return resolveRepository(importPath, useHTTPS, c.Client)
+	}
+	if c.Offline {
+		root, repoURL, ok := c.Imports.lookup(importPath)
+		if !ok {
+			log.Printf("WARN: %s is not in the mirror cache's record of import paths; guessing its repository (--offline)", importPath)
+			return guessRepository(importPath, useHTTPS)
+		}
+		if useHTTPS {
+			return root, sshToHTTPS(repoURL)
+		}
+		if strings.HasPrefix(repoURL, "https://") {
+			return root, httpsToSSH(repoURL)
+		}
+		return root, repoURL
+	}
+	root, repoURL = resolveRepository(importPath, useHTTPS, c.Client)
+	if err := c.Imports.record(root, repoURL); err != nil {
+		log.Printf("WARN: could not record the repository of %s in the mirror cache: %v", root, err)
+	}
+	return root, repoURL
+}
+
+// Options holds the command line flags that control how repositories are
+// fetched
+type Options struct {
+	HTTPS         bool
+	AcceptSSHHost bool
+	SkipFsck      bool
+	Canonical     bool
+	Tags          []string // build tags for scanning imports
+	Layout        string   // layoutGOPATH or layoutVersioned
+	Update        bool
+	FixRemotes    bool
+	Fork          string // user or namespace owning the fork for --fork
+	Adopt         bool
+	Depth         int    // --depth for shallow clones
+	Filter        string // --filter for partial clones
+	Sparse        bool
+	MirrorDir     string       // mirror cache directory; "" disables the cache
+	Imports       *importCache // the mirror cache's record of vanity import paths
+	Dissociate    bool
+	Offline       bool
+	ModCacheVCS   string // $GOMODCACHE/cache/vcs to seed clones from; "" disables seeding
+	Submodules    bool
+	LFS           string // lfsSkip, lfsPull, or "" to let git-lfs run as configured
+	Cloner        Cloner // clone backend; nil means the exec backend
+	Retry         RetryPolicy
+	Check         bool        // only check that repositories can be reached, with ls-remote
+	UserConfig    *UserConfig // goget's configuration file; nil means an empty one
+
+	// KnownHostsFile is the known_hosts file for hosts with pinned keys;
+	// "" disables pinning
+	KnownHostsFile string
+
+	GitURLRewrites []urlRewrite // insteadOf rules from the user's git configuration
+
+	// Protocols remembers the hosts where SSH failed during the run; nil
+	// remembers nothing
+	Protocols *protocolMemory
+
+	// Targets serializes the work on each clone directory; nil when only one
+	// dependency is fetched at a time
+	Targets *targetLocks
 }
 
 // GitCommand represents a git command to execute
@@ -39,6 +150,23 @@ type GitCommand struct {
 	URL        string
 	TargetPath string
 	Args       []string
+	Mode       CloneMode
+	Branch     string // branch to check out; "" means the remote's default
+	Origin     string // name of the remote; "" means origin
+	OriginURL  string // URL the remote points at after the clone; "" means GitURL
+	// GitURL is the URL passed to git, which git's own insteadOf rules
+	// rewrite to URL; "" means URL
+	GitURL string
+}
+
+// gitURL returns the URL c passes to git
+func (c *GitCommand) gitURL() string {
+	return cmp.Or(c.GitURL, c.URL)
+}
+
+// originURL returns the URL the remote should point at after the clone
+func (c *GitCommand) originURL() string {
+	return cmp.Or(c.OriginURL, c.gitURL())
 }
 
 // HTTPClient is an interface for making HTTP requests (for testing)
@@ -46,8 +174,9 @@ type HTTPClient interface {
 	Get(url string) (*http.Response, error)
 }
 
-// discoverGoImport fetches the go-import meta tag from a custom domain
-func discoverGoImport(importPath string, client HTTPClient) (vcs, repoURL string, err error) {
+// discoverGoImport fetches the go-import meta tag from a custom domain. prefix
+// is the import path of the repository root.
+func discoverGoImport(importPath string, client HTTPClient) (prefix, vcs, repoURL string, err error) {
 	// Try HTTPS first
 	url := fmt.Sprintf("https://%s?go-get=1", importPath)
 
@@ -59,19 +188,19 @@ func discoverGoImport(importPath string, client HTTPClient) (vcs, repoURL string
 
 	resp, err := client.Get(url)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to fetch %s: %w", url, err)
+		return "", "", "", fmt.Errorf("failed to fetch %s: %w", url, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", "", fmt.Errorf("got status %d from %s", resp.StatusCode, url)
+		return "", "", "", fmt.Errorf("got status %d from %s", resp.StatusCode, url)
 	}
 
 	return parseGoImportMeta(resp.Body, importPath)
 }
 
 // parseGoImportMeta extracts the go-import meta tag from HTML
-func parseGoImportMeta(r io.Reader, importPath string) (vcs, repoURL string, err error) {
+func parseGoImportMeta(r io.Reader, importPath string) (prefix, vcs, repoURL string, err error) {
 	tokenizer := html.NewTokenizer(r)
 
 	for {
@@ -81,9 +210,9 @@ func parseGoImportMeta(r io.Reader, importPath string) (vcs, repoURL string, err
 		case html.ErrorToken:
 			err := tokenizer.Err()
 			if err == io.EOF {
-				return "", "", fmt.Errorf("no go-import meta tag found")
+				return "", "", "", fmt.Errorf("no go-import meta tag found")
 			}
-			return "", "", err
+			return "", "", "", err
 
 		case html.StartTagToken, html.SelfClosingTagToken:
 			token := tokenizer.Token()
@@ -118,7 +247,7 @@ func parseGoImportMeta(r io.Reader, importPath string) (vcs, repoURL string, err
 				continue
 			}
 
-			return vcsType, repoURL, nil
+			return prefix, vcsType, repoURL, nil
 		}
 	}
 }
@@ -160,32 +289,45 @@ func getRepositoryURL(importPath string, useHTTPS bool) string {
 
 // getRepositoryURLWithClient is like getRepositoryURL but accepts an HTTPClient for testing
 func getRepositoryURLWithClient(importPath string, useHTTPS bool, client HTTPClient) string {
+	_, repoURL := resolveRepository(importPath, useHTTPS, client)
+	return repoURL
+}
+
+// resolveRepository returns the import path of the root of the repository
+// containing importPath, and the git URL to clone it from
+func resolveRepository(importPath string, useHTTPS bool, client HTTPClient) (root, repoURL string) {
 	// For custom domains (not github.com, gitlab.com, etc.), try HTTP discovery first
 	if shouldUseDiscovery(importPath) {
-		if vcs, repoURL, err := discoverGoImport(importPath, client); err == nil {
+		if prefix, vcs, repoURL, err := discoverGoImport(importPath, client); err == nil {
 			// We only support git for now
 			if vcs == "git" {
 				// If the user prefers SSH and the discovered URL is HTTPS,
 				// convert it to SSH. The caller will handle fallback to HTTPS
 				// if SSH fails.
 				if !useHTTPS && strings.HasPrefix(repoURL, "https://") {
-					return httpsToSSH(repoURL)
+					return prefix, httpsToSSH(repoURL)
 				}
-				return repoURL
+				return prefix, repoURL
 			}
 			log.Printf("WARN: discovered VCS type %q is not supported, falling back to heuristics", vcs)
 		} else {
 			log.Printf("WARN: failed to discover go-import meta tag: %v, falling back to heuristics", err)
 		}
 	}
+	return guessRepository(importPath, useHTTPS)
+}
 
+// guessRepository is resolveRepository without go-import discovery: it
+// knows the layout of common hosts and Go domains, and otherwise takes the
+// whole import path for the repository
+func guessRepository(importPath string, useHTTPS bool) (root, repoURL string) {
 	// Handle golang.org/x/* packages
 	if repo, ok := strings.CutPrefix(importPath, "golang.org/x/"); ok {
 		// Remove any subpackage paths - just get the main repo name
 		if idx := strings.Index(repo, "/"); idx != -1 {
 			repo = repo[:idx]
 		}
-		return fmt.Sprintf("https://go.googlesource.com/%s", repo)
+		return "golang.org/x/" + repo, fmt.Sprintf("https://go.googlesource.com/%s", repo)
 	}
 
 	// Handle google.golang.org/* packages
@@ -194,7 +336,7 @@ func getRepositoryURLWithClient(importPath string, useHTTPS bool, client HTTPCli
 		if idx := strings.Index(repo, "/"); idx != -1 {
 			repo = repo[:idx]
 		}
-		return fmt.Sprintf("https://github.com/googleapis/%s", repo)
+		return "google.golang.org/" + repo, fmt.Sprintf("https://github.com/googleapis/%s", repo)
 	}
 
 	// Handle go.opentelemetry.io/* packages
@@ -203,13 +345,13 @@ func getRepositoryURLWithClient(importPath string, useHTTPS bool, client HTTPCli
 		if idx := strings.Index(repo, "/"); idx != -1 {
 			repo = repo[:idx]
 		}
-		return fmt.Sprintf("https://github.com/open-telemetry/%s", repo)
+		return "go.opentelemetry.io/" + repo, fmt.Sprintf("https://github.com/open-telemetry/%s", repo)
 	}
 
 	// Default behavior: construct SSH or HTTPS URL from import path
 	parts := strings.Split(importPath, "/")
 	if len(parts) == 0 {
-		return ""
+		return "", ""
 	}
 
 	domain := parts[0]
@@ -219,25 +361,26 @@ func getRepositoryURLWithClient(importPath string, useHTTPS bool, client HTTPCli
 	if isCommonGitHost(domain) && len(parts) >= 3 {
 		// Take only domain/user/repo for the git URL
 		repo := strings.Join(parts[1:3], "/")
+		root = domain + "/" + repo
 		if useHTTPS {
-			return fmt.Sprintf("https://%s/%s.git", domain, repo)
+			return root, fmt.Sprintf("https://%s/%s.git", domain, repo)
 		}
-		return fmt.Sprintf("git@%s:%s.git", domain, repo)
+		return root, fmt.Sprintf("git@%s:%s.git", domain, repo)
 	}
 
 	// For other domains, use the full path (minus domain)
 	if len(parts) > 1 {
 		repo := strings.Join(parts[1:], "/")
 		if useHTTPS {
-			return fmt.Sprintf("https://%s/%s.git", domain, repo)
+			return importPath, fmt.Sprintf("https://%s/%s.git", domain, repo)
 		}
-		return fmt.Sprintf("git@%s:%s.git", domain, repo)
+		return importPath, fmt.Sprintf("git@%s:%s.git", domain, repo)
 	}
 
 	if useHTTPS {
-		return fmt.Sprintf("https://%s.git", domain)
+		return importPath, fmt.Sprintf("https://%s.git", domain)
 	}
-	return fmt.Sprintf("git@%s.git", domain)
+	return importPath, fmt.Sprintf("git@%s.git", domain)
 }
 
 // isCommonGitHost returns true for well-known Git hosting services
@@ -282,6 +425,14 @@ func validateImportPath(importPath string) error {
 
 // resolveConfig takes raw inputs and produces a validated Config
 func resolveConfig(arg, gopath, workingDir string) (*Config, error) {
+	arg, src, err := normalizeInput(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	// path@version, as accepted by "go get"
+	arg, version, _ := strings.Cut(arg, "@")
+
 	importPath, hasEllipsis, err := parseImportPath(arg)
 	if err != nil {
 		return nil, err
@@ -305,12 +456,19 @@ func resolveConfig(arg, gopath, workingDir string) (*Config, error) {
 		return nil, fmt.Errorf("could not get absolute directory for gopath: %v", err)
 	}
 
-	return &Config{
+	config := &Config{
 		GOPATH:      absGopath,
 		WorkingDir:  workingDir,
 		ImportPath:  importPath,
 		HasEllipsis: hasEllipsis,
-	}, nil
+		Version:     version,
+	}
+	if src != nil {
+		config.RepoURL = src.URL
+		config.RepoRoot = src.Root
+		config.Branch = src.Branch
+	}
+	return config, nil
 }
 
 // buildGitCommand creates the git command from the config
@@ -340,85 +498,141 @@ func buildGitCommand(config *Config, useHTTPS bool) (*GitCommand, error) {
 		pkgstart := strings.TrimPrefix(rel, "src/")
 		fullpkg := filepath.Join(pkgstart, config.ImportPath)
 		checkoutPath = config.ImportPath
-		gitURL = getRepositoryURL(fullpkg, useHTTPS)
+		_, gitURL = config.resolveRepository(fullpkg, useHTTPS)
+	} else if config.RepoURL != "" {
+		// The user gave us a URL; clone exactly that, with the protocol
+		// they chose
+		checkoutPath = filepath.Join(config.GOPATH, "src", config.RepoRoot)
+		gitURL = config.RepoURL
 	} else {
-		checkoutPath = filepath.Join(config.GOPATH, "src", config.ImportPath)
-		gitURL = getRepositoryURL(config.ImportPath, useHTTPS)
+		// Clone the repository root, not the subpackage that was asked for
+		root, repoURL := config.resolveRepository(config.ImportPath, useHTTPS)
+		checkoutPath = filepath.Join(config.GOPATH, "src", root)
+		gitURL = repoURL
 	}
 
 	if gitURL == "" {
 		return nil, fmt.Errorf("could not determine git URL for %v", config.ImportPath)
 	}
 
+	args := []string{"clone", "--quiet"}
+	if config.Branch != "" {
+		args = append(args, "--branch", config.Branch)
+	}
+	args = append(args, gitURL, checkoutPath)
+
 	return &GitCommand{
 		URL:        gitURL,
 		TargetPath: checkoutPath,
-		Args:       []string{"clone", "--quiet", gitURL, checkoutPath},
+		Args:       args,
+		Branch:     config.Branch,
 	}, nil
 }
 
-// executeGitCommand runs the git command
-// Returns (skipped=true, nil) if the repo already exists, (skipped=false, nil) if cloned successfully, or (skipped=false, err) on error
-func executeGitCommand(ctx context.Context, cmd *GitCommand, acceptSSHHost, skipFsck bool) (skipped bool, err error) {
+// existingCheckout reports what marks dir as an existing checkout: "go.mod",
+// ".git", or "" if it is neither
+func existingCheckout(dir string) string {
 	// Check if a go.mod file exists at the target path
 	// This handles monorepos where the .git is at a parent level
-	modFile := filepath.Join(cmd.TargetPath, "go.mod")
-	if _, err := os.Stat(modFile); err == nil {
-		fmt.Printf("Package already exists at %s (go.mod found), skipping clone\n", cmd.TargetPath)
-		return true, nil
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		return "go.mod"
 	}
 
 	// Also check for .git directory at the exact target path (for non-module repos)
-	gitDir := filepath.Join(cmd.TargetPath, ".git")
-	if _, err := os.Stat(gitDir); err == nil {
-		fmt.Printf("Repository already exists at %s, skipping clone\n", cmd.TargetPath)
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return ".git"
+	}
+	return ""
+}
+
+// executeGitCommand runs the git command
+// Returns (skipped=true, nil) if the repo already exists, (skipped=false, nil) if cloned successfully, or (skipped=false, err) on error
+func executeGitCommand(ctx context.Context, cmd *GitCommand, opts Options) (skipped bool, err error) {
+	// A previous --canonical run moved this clone to its declared import path
+	if note, err := os.ReadFile(filepath.Join(cmd.TargetPath, movedNoteName)); err == nil {
+		fmt.Printf("Skipping %s: %s", cmd.TargetPath, note)
 		return true, nil
 	}
 
-	args := cmd.Args
-	if skipFsck {
-		args = append([]string{"-c", "transfer.fsckObjects=false", "-c", "fetch.fsckObjects=false"}, args...)
+	checkout := existingCheckout(cmd.TargetPath)
+	if checkout != "" {
+		// A run killed while another one cloned the same repository leaves
+		// its temporary directory next to the clone
+		cleanStaleTempDirs(cmd.TargetPath)
+	}
+	switch checkout {
+	case "go.mod":
+		fmt.Printf("Package already exists at %s (go.mod found), skipping clone\n", cmd.TargetPath)
+		return true, nil
+	case ".git":
+		fmt.Printf("Repository already exists at %s, skipping clone\n", cmd.TargetPath)
+		return true, nil
+	}
+	if needsAdoption(cmd.TargetPath) {
+		return false, fmt.Errorf("%w: %s is not a git checkout (use --adopt to turn it into one)", ErrDestinationExists, cmd.TargetPath)
 	}
-	gitCmd := exec.CommandContext(ctx, "git", args...)
-	gitCmd.Stdout = os.Stdout
 
-	// Configure SSH to fail fast instead of hanging on prompts
-	if strings.HasPrefix(cmd.URL, "git@") {
-		sshOpts := "ssh -o BatchMode=yes"
-		if acceptSSHHost {
-			// Accept new host keys automatically (but still reject changed keys)
-			sshOpts = "ssh -o BatchMode=yes -o StrictHostKeyChecking=accept-new"
-		}
-		gitCmd.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshOpts)
+	if err := preflight(ctx, cmd, opts); err != nil {
+		return false, err
 	}
 
-	// Capture stderr to detect SSH host key errors
-	var stderrBuf bytes.Buffer
-	gitCmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
+	// Clone into a temporary sibling directory and move it into place only
+	// once the clone is complete, so a failed or interrupted clone never
+	// leaves a partial tree that later runs would mistake for a clone
+	tmpDir, err := prepareTempDir(cmd.TargetPath)
+	if err != nil {
+		return false, fmt.Errorf("could not create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir) // does nothing once the clone has been moved
 
-	runErr := gitCmd.Run()
-	if runErr != nil {
-		stderr := stderrBuf.String()
-		// Check for SSH host key verification failure
-		if strings.Contains(stderr, "Host key verification failed") ||
-			strings.Contains(stderr, "host key") {
-			// Extract the hostname from the git URL for the hint
-			host := extractHostFromGitURL(cmd.URL)
-			hint := fmt.Sprintf("\nSSH host key verification failed for %s.\n", host)
-			hint += "To fix this, you can:\n"
-			hint += fmt.Sprintf("  1. Add the host to known_hosts: ssh-keyscan %s >> ~/.ssh/known_hosts\n", host)
-			hint += "  2. Connect manually once: ssh -T git@" + host + "\n"
-			hint += "  3. Use --accept-ssh-host flag to auto-accept new host keys\n"
-			hint += "  4. Use --https flag to clone via HTTPS instead\n"
-			return false, fmt.Errorf("%w%s", runErr, hint)
+	cloner := opts.cloner()
+	if err := cloner.Clone(ctx, cmd, tmpDir, opts); err != nil {
+		return false, err
+	}
+	if !cloner.Exists(ctx, tmpDir) {
+		return false, fmt.Errorf("clone in %s is not a git repository", tmpDir)
+	}
+	if err := os.Rename(tmpDir, cmd.TargetPath); err != nil {
+		if _, statErr := os.Stat(cmd.TargetPath); statErr == nil {
+			err = fmt.Errorf("%w: %w", ErrDestinationExists, err)
 		}
+		return false, fmt.Errorf("could not move clone into place: %w", err)
 	}
+	return false, nil
+}
+
+// gitOutput runs git with the given arguments in dir and returns its trimmed
+// standard output. On failure, the error includes git's standard error.
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	return gitOutputEnv(ctx, dir, nil, args...)
+}
 
-	return false, runErr
+// gitOutputEnv is like gitOutput, but runs git with the given environment
+// (nil means the current process's environment)
+func gitOutputEnv(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", newGitError(ctx, "", args, stderr.String(), err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
 }
 
 // extractHostFromGitURL extracts the hostname from a git URL like git@github.com:user/repo.git
 func extractHostFromGitURL(url string) string {
+	if _, after, ok := strings.Cut(url, "://"); ok {
+		// Format: scheme://[user@]hostname[:port]/path
+		url, _, _ = strings.Cut(after, "/")
+		if _, host, ok := strings.Cut(url, "@"); ok {
+			url = host
+		}
+		url, _, _ = strings.Cut(url, ":")
+		return url
+	}
 	if after, ok := strings.CutPrefix(url, "git@"); ok {
 		// Format: git@hostname:path
 		url = after
@@ -446,7 +660,8 @@ func httpsToSSH(url string) string {
 		return url // No path, can't convert
 	}
 
-	host := before
+	// The HTTPS port says nothing about where SSH listens
+	host, _, _ := strings.Cut(before, ":")
 	path := after
 
 	// Ensure .git suffix
@@ -457,15 +672,89 @@ func httpsToSSH(url string) string {
 	return fmt.Sprintf("git@%s:%s", host, path)
 }
 
+// Requirement is a module path and version from a go.mod require directive
+type Requirement struct {
+	Path    string
+	Version string
+}
+
+// sshToHTTPS converts an SSH git URL to an HTTPS git URL, the inverse of
+// httpsToSSH.
+// For example: git@github.com:user/repo.git -> https://github.com/user/repo.git
+// ssh:// and git+ssh:// URLs lose their user and port. Returns the original
+// URL if it's not an SSH URL.
+func sshToHTTPS(url string) string {
+	var host, path string
+	after, ok := strings.CutPrefix(url, "ssh://")
+	if !ok {
+		after, ok = strings.CutPrefix(url, "git+ssh://")
+	}
+	if ok {
+		hostPort, rest, ok := strings.Cut(after, "/")
+		if !ok {
+			return url
+		}
+		if _, h, ok := strings.Cut(hostPort, "@"); ok {
+			hostPort = h
+		}
+		host, _, _ = strings.Cut(hostPort, ":")
+		path = rest
+	} else if isSSHURL(url) {
+		m := scpLikeURL.FindStringSubmatch(url)
+		if m == nil {
+			return url
+		}
+		host, path = m[1], strings.TrimPrefix(m[2], "/")
+	} else {
+		return url
+	}
+
+	// Ensure .git suffix
+	if !strings.HasSuffix(path, ".git") {
+		path = path + ".git"
+	}
+
+	return fmt.Sprintf("https://%s/%s", host, path)
+}
+
+// sameRemote reports whether two git URLs point at the same repository,
+// treating the SSH and HTTPS forms of a URL as equal
+func sameRemote(a, b string) bool {
+	normalize := func(url string) string {
+		url = sshToHTTPS(url)
+		url = strings.TrimPrefix(url, "https://")
+		url = strings.TrimPrefix(url, "http://")
+		url = strings.TrimSuffix(url, "/")
+		url = strings.TrimSuffix(url, ".git")
+		host, path, _ := strings.Cut(url, "/")
+		return strings.ToLower(host) + "/" + path
+	}
+	return normalize(a) == normalize(b)
+}
+
 // parseGoMod parses a go.mod file and returns all dependencies (both direct and indirect)
 func parseGoMod(modPath string) ([]string, error) {
+	reqs, err := parseGoModRequires(modPath)
+	if err != nil {
+		return nil, err
+	}
+	deps := make([]string, len(reqs))
+	for i, req := range reqs {
+		deps[i] = req.Path
+	}
+	return deps, nil
+}
+
+// parseGoModRequires is like parseGoMod, but also returns the required version
+// of each dependency
+func parseGoModRequires(modPath string) ([]Requirement, error) {
 	file, err := os.Open(modPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open go.mod file: %w", err)
 	}
 	defer file.Close()
 
-	var deps []string
+	var deps []Requirement
 	scanner := bufio.NewScanner(file)
 	inRequireBlock := false
 
@@ -499,7 +788,7 @@ func parseGoMod(modPath string) ([]string, error) {
 		// Extract the package path (first field before version)
 		fields := strings.Fields(depLine)
 		if len(fields) >= 2 {
-			deps = append(deps, fields[0])
+			deps = append(deps, Requirement{Path: fields[0], Version: fields[1]})
 		}
 	}
 
@@ -510,23 +799,71 @@ func parseGoMod(modPath string) ([]string, error) {
 	return deps, nil
 }
 
+// maxParallel is the number of git commands goget runs at once
+const maxParallel = 10
+
 // DependencyResult holds the result of fetching a single dependency
 type DependencyResult struct {
 	ImportPath string
 	Error      error
 	Skipped    bool
+
+	// Set with -u for repositories that already existed
+	Update       updateStatus
+	UpdateReason string
+
+	// Set with --adopt for directories that were not git checkouts
+	Adopted string
+
+	// Mode is how a fresh clone was made, e.g. "full" or "depth=1"
+	Mode string
+
+	// Retries is the number of times a clone or go-import lookup was retried
+	Retries int
+
+	// Source is where a fresh clone came from: "upstream", or a mirror
+	Source string
+}
+
+// targetLocks holds a mutex per clone directory. Dependencies in the same
+// repository, such as a module and its nested modules, resolve to the same
+// directory: the first clones it, and the others wait, find the clone and
+// skip it.
+type targetLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock locks the directory path and returns the function that unlocks it
+func (l *targetLocks) lock(path string) (unlock func()) {
+	if l == nil {
+		return func() {}
+	}
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*sync.Mutex)
+	}
+	m, ok := l.locks[path]
+	if !ok {
+		m = new(sync.Mutex)
+		l.locks[path] = m
+	}
+	l.mu.Unlock()
+	m.Lock()
+	return m.Unlock
 }
 
 // runGoGetParallel fetches multiple dependencies in parallel
-func runGoGetParallel(ctx context.Context, deps []string, gopath, workingDir string, useHTTPS, acceptSSHHost, skipFsck bool) []DependencyResult {
+func runGoGetParallel(ctx context.Context, deps []string, gopath, workingDir string, opts Options) []DependencyResult {
 	results := make([]DependencyResult, len(deps))
+	opts.Targets = &targetLocks{}
 
 	// Use a mutex to ensure git output doesn't get interleaved
 	var outputMutex sync.Mutex
 
 	// Use errgroup with concurrency limit to avoid spawning too many goroutines
 	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(10) // Limit to 10 concurrent git clones
+	g.SetLimit(maxParallel)
 
 	for i, dep := range deps {
 		idx := i
@@ -537,17 +874,19 @@ func runGoGetParallel(ctx context.Context, deps []string, gopath, workingDir str
 			fmt.Printf("\n[%d/%d] Fetching %s...\n", idx+1, len(deps), importPath)
 			outputMutex.Unlock()
 
-			skipped, err := runGoGet(ctx, importPath, gopath, workingDir, useHTTPS, acceptSSHHost, skipFsck)
-			results[idx] = DependencyResult{
-				ImportPath: importPath,
-				Error:      err,
-				Skipped:    skipped,
-			}
+			result, err := runGoGet(ctx, importPath, gopath, workingDir, opts)
+			result.ImportPath = importPath
+			result.Error = err
+			results[idx] = result
 
 			outputMutex.Lock()
 			if err != nil {
 				fmt.Printf("[%d/%d] ERROR: Failed to fetch %s: %v\n", idx+1, len(deps), importPath, err)
-			} else if skipped {
+			} else if result.Adopted != "" {
+				fmt.Printf("[%d/%d] ADOPTED: %s\n", idx+1, len(deps), importPath)
+			} else if result.Update != "" {
+				fmt.Printf("[%d/%d] %s: %s\n", idx+1, len(deps), strings.ToUpper(string(result.Update)), importPath)
+			} else if result.Skipped {
 				fmt.Printf("[%d/%d] SKIPPED: %s\n", idx+1, len(deps), importPath)
 			} else {
 				fmt.Printf("[%d/%d] SUCCESS: Fetched %s\n", idx+1, len(deps), importPath)
@@ -566,11 +905,12 @@ func runGoGetParallel(ctx context.Context, deps []string, gopath, workingDir str
 }
 
 // runGoGet is the main logic, extracted from main() for testability
-// Returns (skipped=true, nil) if the repo already exists, (skipped=false, nil) if cloned successfully, or (skipped=false, err) on error
-func runGoGet(ctx context.Context, arg, gopath, workingDir string, useHTTPS, acceptSSHHost, skipFsck bool) (skipped bool, err error) {
+// The result has Skipped set if the repo already exists, and Update set if it
+// was updated with -u. The caller fills in ImportPath and Error.
+func runGoGet(ctx context.Context, arg, gopath, workingDir string, opts Options) (result DependencyResult, err error) {
 	config, err := resolveConfig(arg, gopath, workingDir)
 	if err != nil {
-		return false, err
+		return result, err
 	}
 
 	if config.HasEllipsis {
@@ -581,56 +921,463 @@ func runGoGet(ctx context.Context, arg, gopath, workingDir string, useHTTPS, acc
 		log.Printf("WARN: multiple paths in GOPATH; goget only works with first one")
 	}
 
+	client := &retryingClient{ctx: ctx, client: &http.Client{Timeout: 10 * time.Second}, policy: opts.Retry}
+	config.Client = client
+	config.Imports, config.Offline = opts.Imports, opts.Offline
+	defer func() { result.Retries += client.retries }()
+
+	useHTTPS := opts.HTTPS
+	if opts.Fork != "" {
+		// With --fork, upstream is a read-only HTTPS remote
+		useHTTPS = true
+		config.RepoURL = sshToHTTPS(config.RepoURL)
+	}
+
 	gitCmd, err := buildGitCommand(config, useHTTPS)
 	if err != nil {
-		return false, err
+		return result, err
+	}
+	defer opts.Targets.lock(filepath.Clean(gitCmd.TargetPath))()
+	// The protocol preference for the host git really connects to, after
+	// the rewrite rules, decides whether the clone starts with SSH. sshURL
+	// is set if it does, and sshRetry if HTTPS was chosen only because SSH
+	// to the host failed before.
+	var sshURL string
+	var sshRetry *GitCommand
+	if effective := effectiveURL(gitCmd.URL, opts); !useHTTPS && config.RepoURL == "" && isSSHURL(effective) {
+		https, remembered, reason := preferHTTPS(effective, opts)
+		var httpsCmd *GitCommand
+		if https {
+			httpsCmd, err = buildGitCommand(config, true)
+			if err != nil {
+				return result, err
+			}
+		}
+		// The rules may send HTTPS to SSH as well
+		if httpsCmd != nil && !isSSHURL(effectiveURL(httpsCmd.URL, opts)) {
+			fmt.Printf("Using HTTPS for %s (%s)\n", extractHostFromGitURL(effective), reason)
+			if remembered {
+				sshRetry = gitCmd
+			}
+			gitCmd = httpsCmd
+		} else {
+			sshURL = effective
+		}
 	}
 
-	fmt.Printf("git %s\n", strings.Join(gitCmd.Args, " "))
+	gitCmd.setMode(cloneMode(opts, config, gitCmd.TargetPath))
 
-	skipped, err = executeGitCommand(ctx, gitCmd, acceptSSHHost, skipFsck)
-	if err != nil {
-		// If SSH clone failed and we weren't explicitly using HTTPS,
-		// try falling back to HTTPS
-		if !useHTTPS && strings.HasPrefix(gitCmd.URL, "git@") {
-			log.Printf("SSH clone failed, falling back to HTTPS...")
-			httpsCmd, httpsErr := buildGitCommand(config, true)
-			if httpsErr == nil {
-				fmt.Printf("git %s\n", strings.Join(httpsCmd.Args, " "))
-				skipped, err = executeGitCommand(ctx, httpsCmd, acceptSSHHost, skipFsck)
+	var forkRemote, forkPush string
+	if opts.Fork != "" {
+		forkRemote, err = forkURL(gitCmd.URL, opts.Fork)
+		if err != nil {
+			return result, err
+		}
+		gitCmd.Origin = upstreamRemoteName
+		gitCmd.Args = slices.Insert(gitCmd.Args, 1, "--origin", upstreamRemoteName)
+		forkPush = gogetPushURL(forkRemote, opts)
+		push := effectivePushURL(forkRemote, opts)
+		var fetch string
+		forkRemote, fetch = rewrittenURLs(forkRemote, opts)
+		if push != fetch {
+			fmt.Printf("Pushes to the fork %s go to %s\n", fetch, push)
+		}
+	}
+	// Mirrors are looked up by the canonical URL; from here on, everything
+	// sees the URL git will really fetch from
+	sources := cloneSources(gitCmd.URL, opts)
+	gitCmd.applyRewrites(opts)
+	sshFallback := func(err error) *GitCommand {
+		if err == nil || opts.HTTPS || config.RepoURL != "" || !isSSHURL(gitCmd.URL) || !sshFallbackWorthwhile(err) {
+			return nil
+		}
+		httpsCmd, err := buildGitCommand(config, true)
+		if err != nil {
+			return nil
+		}
+		httpsCmd.applyRewrites(opts)
+		if isSSHURL(httpsCmd.URL) {
+			// The user's insteadOf rules send HTTPS to SSH anyway
+			return nil
+		}
+		return httpsCmd
+	}
+
+	fallbackSource, fallback := sourceUpstreamHTTPS, sshFallback
+	if sshRetry != nil {
+		fallbackSource = sourceUpstreamSSH
+		fallback = func(err error) *GitCommand {
+			if err == nil || !sshRetryWorthwhile(err) {
+				return nil
 			}
+			sshRetry.applyRewrites(opts)
+			return sshRetry
+		}
+	}
+
+	candidates := cloneCandidates(gitCmd, sources, opts, fallbackSource, fallback)
+	if opts.Check {
+		// Only see which source can be reached
+		err := checkCandidates(ctx, candidates, opts, &result)
+		if result.Source == sourceUpstreamHTTPS && sshURL != "" {
+			opts.Protocols.rememberHTTPS(sshURL, opts)
+		}
+		return result, err
+	}
+
+	if opts.Adopt && needsAdoption(gitCmd.TargetPath) {
+		if !gitInstalled() {
+			return result, errNoGit("--adopt")
+		}
+		fmt.Printf("Adopting %s as a clone of %s\n", gitCmd.TargetPath, gitCmd.URL)
+		result.Adopted, err = adoptDirectory(ctx, gitCmd.TargetPath, gitCmd.gitURL(), opts)
+		if httpsCmd := sshFallback(err); httpsCmd != nil {
+			log.Printf("SSH fetch failed (%v), falling back to HTTPS...", causeName(err))
+			gitCmd = httpsCmd
+			result.Adopted, err = adoptDirectory(ctx, gitCmd.TargetPath, gitCmd.gitURL(), opts)
 		}
 		if err != nil {
-			return false, fmt.Errorf("error running git %v: %v", strings.Join(gitCmd.Args, " "), err)
+			return result, fmt.Errorf("could not adopt %s: %w", gitCmd.TargetPath, err)
 		}
+		fmt.Printf("Adopted %s: %s\n", gitCmd.TargetPath, result.Adopted)
+	}
+
+	var skipped bool
+	if result.Adopted == "" {
+		// Try each source in turn. After a mirror clone, origin points at
+		// upstream, and so does everything below.
+		var cloned *GitCommand
+		cloned, skipped, err = cloneFromCandidates(ctx, candidates, opts, &result)
+		if err != nil {
+			return result, err
+		}
+		if cloned.OriginURL == "" {
+			gitCmd = cloned
+		}
+		if result.Source == sourceUpstreamHTTPS && sshURL != "" {
+			opts.Protocols.rememberHTTPS(sshURL, opts)
+		}
+	}
+
+	repoDir := gitCmd.TargetPath
+	if !skipped && result.Adopted == "" {
+		result.Mode = gitCmd.Mode.String()
+		if !gitCmd.Mode.IsFull() {
+			fmt.Printf("Cloned %s (%s)\n", gitCmd.TargetPath, result.Mode)
+		}
+	}
+	if !skipped {
+		repoDir, err = checkCanonicalPath(config.GOPATH, gitCmd.TargetPath, opts.Canonical)
+		if err != nil {
+			return result, err
+		}
+		if forkRemote != "" {
+			if !gitInstalled() {
+				return result, errNoGit("--fork")
+			}
+			if err := configureFork(ctx, repoDir, gitCmd.gitURL(), forkRemote, forkPush); err != nil {
+				return result, err
+			}
+		}
+		if opts.Submodules && !opts.recursesSubmodules() {
+			if err := updateSubmodules(ctx, repoDir, gitCmd.URL, opts); err != nil {
+				return result, err
+			}
+		}
+		if err := handleLFS(ctx, repoDir, opts); err != nil {
+			return result, err
+		}
+	} else if !hasMovedNote(repoDir) {
+		// The clone already exists: check that it points where we expect,
+		// and bring it up to date with -u
+		if forkRemote != "" {
+			if !gitInstalled() {
+				return result, errNoGit("--fork")
+			}
+			if err := configureFork(ctx, repoDir, gitCmd.gitURL(), forkRemote, forkPush); err != nil {
+				return result, err
+			}
+		} else if !gitInstalled() {
+			log.Printf("WARN: not checking the origin of %s: git is not installed", repoDir)
+		} else if _, err := verifyRemote(ctx, repoDir, gitCmd.gitURL(), opts.FixRemotes); err != nil {
+			return result, err
+		}
+		if opts.Update && opts.Offline {
+			log.Printf("WARN: not updating %s with --offline", repoDir)
+		} else if opts.Update && !gitInstalled() {
+			log.Printf("WARN: not updating %s: -u needs git to merge, and git is not installed", repoDir)
+		} else if opts.Update {
+			result.Update, result.UpdateReason, err = updateRepo(ctx, repoDir, opts)
+			if err != nil {
+				return result, err
+			}
+			fmt.Printf("%s: %s\n", result.Update, result.UpdateReason)
+		}
+	}
+
+	// We clone the repository root; make sure the package that was asked
+	// for actually exists in it
+	if !strings.HasPrefix(config.ImportPath, ".") {
+		warnMissingSubpackage(config, gitCmd.TargetPath, repoDir)
+	}
+
+	if opts.Layout == layoutVersioned && !strings.HasPrefix(config.ImportPath, ".") {
+		if config.Version == "" {
+			log.Printf("WARN: no version given for %s; use path@version with --layout=versioned", config.ImportPath)
+		} else if !gitInstalled() {
+			return result, errNoGit("--layout=versioned")
+		} else {
+			// Skipping is decided per version; the canonical clone is
+			// only the shared object store
+			skipped, err = addVersionedCheckout(ctx, config, repoDir, opts)
+			if err != nil {
+				return result, err
+			}
+		}
+	} else if config.Version != "" {
+		log.Printf("WARN: ignoring version %s; only --layout=versioned checks out versions", config.Version)
 	}
 
 	if config.HasEllipsis && !skipped {
 		fmt.Printf("Successfully cloned %s (note: /... means this package and all subpackages)\n", config.ImportPath)
 	}
 
-	return skipped, nil
+	result.Skipped = skipped
+	return result, nil
+}
+
+// cloneWithRetry runs executeGitCommand under the retry policy in opts,
+// adding the retries it made to result. Each attempt clones into a fresh
+// temporary directory, and a failed attempt's directory is removed before
+// the next one starts.
+func cloneWithRetry(ctx context.Context, cmd *GitCommand, opts Options, result *DependencyResult) (skipped bool, err error) {
+	retries, err := opts.Retry.retry(ctx, "clone of "+cmd.URL, func() error {
+		skipped, err = executeGitCommand(ctx, cmd, opts)
+		return err
+	})
+	result.Retries += retries
+	return skipped, err
+}
+
+// warnMissingSubpackage logs a warning if the subpackage named by the import
+// path does not exist in the repository cloned to targetPath (and possibly
+// moved to repoDir since), listing any close matches
+func warnMissingSubpackage(config *Config, targetPath, repoDir string) {
+	pkgDir := filepath.Join(config.GOPATH, "src", config.ImportPath)
+	subdir, err := filepath.Rel(targetPath, pkgDir)
+	if err != nil || subdir == "." || strings.HasPrefix(subdir, "..") {
+		return
+	}
+	subdir = filepath.ToSlash(subdir)
+	if majorVersionDir.MatchString(subdir) {
+		// example.com/foo/v2 may live on a branch rather than in a directory
+		return
+	}
+
+	found, suggestions := findSubpackage(repoDir, subdir)
+	if found {
+		return
+	}
+	log.Printf("WARN: %s does not contain a Go package at %s", repoDir, subdir)
+	if len(suggestions) > 0 {
+		log.Printf("WARN: did you mean one of these?")
+		for _, suggestion := range suggestions {
+			log.Printf("WARN:   %s", suggestion)
+		}
+	}
+}
+
+// printSummary prints a summary of a batch of fetches and reports whether
+// any of them failed
+func printSummary(results []DependencyResult) (failed bool) {
+	fmt.Println("\n" + strings.Repeat("=", 60))
+	fmt.Println("SUMMARY")
+	fmt.Println(strings.Repeat("=", 60))
+
+	successCount := 0
+	failureCount := 0
+	for _, result := range results {
+		if result.Error == nil {
+			successCount++
+		} else {
+			failureCount++
+		}
+	}
+
+	fmt.Printf("Total: %d | Success: %d | Failed: %d\n", len(results), successCount, failureCount)
+	printUpdateSummary(results)
+	printAdoptSummary(results)
+	printCloneModeSummary(results)
+	printRetrySummary(results)
+	printSourceSummary(results)
+
+	if failureCount > 0 {
+		fmt.Println("\nFailed dependencies:")
+		for _, group := range groupFailures(results) {
+			fmt.Printf("\n  %s (%d):\n", causeName(group.Cause), len(group.Results))
+			errs := make([]error, len(group.Results))
+			for i, result := range group.Results {
+				fmt.Printf("  - %s: %v\n", result.ImportPath, result.Error)
+				errs[i] = result.Error
+			}
+			if hint := causeHint(group.Cause, errorHosts(errs)); hint != "" {
+				fmt.Print("\n" + hint)
+			}
+		}
+	}
+	return failureCount > 0
+}
+
+// runFetchImports handles --deps: it fetches the missing imports of the
+// package named by arg, or of the current directory tree if arg is empty
+func runFetchImports(ctx context.Context, arg, gopath, workingDir string, opts Options) ([]DependencyResult, error) {
+	dir, recursive := workingDir, true
+	if arg != "" {
+		config, err := resolveConfig(arg, gopath, workingDir)
+		if err != nil {
+			return nil, err
+		}
+		dir, recursive = config.ImportPath, config.HasEllipsis
+		if !strings.HasPrefix(config.ImportPath, ".") {
+			dir = filepath.Join(config.GOPATH, "src", config.ImportPath)
+		}
+	}
+	fmt.Printf("Scanning imports in %s...\n", dir)
+	return fetchImports(ctx, dir, recursive, gopath, workingDir, opts)
 }
 
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
+	// Cancel running clones on SIGINT/SIGTERM; executeGitCommand then
+	// removes their temporary directories
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
+	go func() {
+		// Restore the default handling after the first signal, so a second
+		// Ctrl-C exits right away if the cleanup hangs
+		<-ctx.Done()
+		cancel()
+	}()
 
 	flag.Parse()
 
+	opts := Options{
+		HTTPS:         *httpsFlag,
+		AcceptSSHHost: *acceptSSHHostFlag,
+		SkipFsck:      *skipFsckFlag,
+		Canonical:     *canonicalFlag,
+		Layout:        *layoutFlag,
+		Update:        *updateFlag,
+		FixRemotes:    *fixRemotesFlag,
+		Fork:          *forkFlag,
+		Adopt:         *adoptFlag,
+		Depth:         *depthFlag,
+		Filter:        *filterFlag,
+		Sparse:        *sparseFlag,
+		Dissociate:    *dissociateFlag,
+		Offline:       *offlineFlag,
+		Submodules:    *submodulesFlag,
+		LFS:           *lfsFlag,
+		Retry:         RetryPolicy{MaxAttempts: *maxAttemptsFlag, Deadline: *retryDeadlineFlag},
+		Check:         *checkFlag,
+	}
+	if opts.Layout != layoutGOPATH && opts.Layout != layoutVersioned {
+		log.Fatalf("unknown --layout %q: must be %q or %q", opts.Layout, layoutGOPATH, layoutVersioned)
+	}
+	if opts.Retry.MaxAttempts < 1 {
+		log.Fatalf("--max-attempts must be at least 1, got %d", opts.Retry.MaxAttempts)
+	}
+	if opts.Check && (opts.Offline || *depsFlag) {
+		log.Fatal("--check can't be combined with --offline or --deps")
+	}
+	if !validFilter(opts.Filter) {
+		log.Fatalf("unknown --filter %q: must be %q or %q", opts.Filter, filterBlobless, filterTreeless)
+	}
+	if *mirrorFlag || *offlineFlag || flag.Arg(0) == "mirror" {
+		mirrorDir, err := mirrorCacheDir()
+		if err != nil {
+			log.Fatalf("could not determine mirror cache directory: %v", err)
+		}
+		opts.MirrorDir = mirrorDir
+		opts.Imports = newImportCache(mirrorDir)
+	}
+	if opts.LFS != "" && opts.LFS != lfsSkip && opts.LFS != lfsPull {
+		log.Fatalf("unknown --lfs %q: must be %q or %q", opts.LFS, lfsSkip, lfsPull)
+	}
+	configPath := *configFlag
+	if configPath == "" {
+		if path, err := defaultConfigPath(); err == nil {
+			configPath = path
+		}
+	}
+	if configPath != "" {
+		userConfig, err := loadUserConfig(configPath, *configFlag != "")
+		if err != nil {
+			log.Fatalf("could not load configuration: %v", err)
+		}
+		opts.UserConfig = userConfig
+	}
+	opts.GitURLRewrites = gitURLRewrites(ctx)
+	opts.Protocols = &protocolMemory{}
+	if *pinHostKeysFlag {
+		knownHosts, err := knownHostsPath()
+		if err != nil {
+			log.Fatalf("could not determine known_hosts path: %v", err)
+		}
+		opts.KnownHostsFile = knownHosts
+	}
+	cloner, err := newCloner(*backendFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts.Cloner = cloner
+	if *tagsFlag != "" {
+		opts.Tags = strings.Split(*tagsFlag, ",")
+	}
+
 	gopath := os.Getenv("GOPATH")
+	if *modCacheFlag {
+		opts.ModCacheVCS = modCacheVCSDir(gopath)
+	}
 	workingDir, err := os.Getwd()
 	if err != nil {
 		log.Fatalf("could not determine working directory: %v", err)
 	}
 
+	if *unshallowFlag != "" {
+		if err := runUnshallow(ctx, *unshallowFlag, gopath, opts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "mirror" {
+		if err := runMirror(ctx, flag.Args()[1:], opts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "status" {
+		if err := runStatus(ctx, flag.Args()[1:], gopath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Handle --mod flag
 	if *modFlag != "" {
 		fmt.Printf("Parsing dependencies from %s...\n", *modFlag)
-		deps, err := parseGoMod(*modFlag)
+		reqs, err := parseGoModRequires(*modFlag)
 		if err != nil {
 			log.Fatal(err)
 		}
+		deps := make([]string, len(reqs))
+		for i, req := range reqs {
+			deps[i] = req.Path
+			if opts.Layout == layoutVersioned {
+				deps[i] += "@" + req.Version
+			}
+		}
 
 		if len(deps) == 0 {
 			fmt.Println("No dependencies found in go.mod")
@@ -638,45 +1385,39 @@ func main() {
 		}
 
 		fmt.Printf("Found %d dependencies (direct and indirect)\n", len(deps))
-		results := runGoGetParallel(ctx, deps, gopath, workingDir, *httpsFlag, *acceptSSHHostFlag, *skipFsckFlag)
-
-		// Print summary
-		fmt.Println("\n" + strings.Repeat("=", 60))
-		fmt.Println("SUMMARY")
-		fmt.Println(strings.Repeat("=", 60))
-
-		successCount := 0
-		failureCount := 0
-		for _, result := range results {
-			if result.Error == nil {
-				successCount++
-			} else {
-				failureCount++
-			}
-		}
-
-		fmt.Printf("Total: %d | Success: %d | Failed: %d\n", len(results), successCount, failureCount)
-
-		if failureCount > 0 {
-			fmt.Println("\nFailed dependencies:")
-			for _, result := range results {
-				if result.Error != nil {
-					fmt.Printf("  - %s: %v\n", result.ImportPath, result.Error)
-				}
-			}
+		results := runGoGetParallel(ctx, deps, gopath, workingDir, opts)
+		if printSummary(results) {
 			os.Exit(1)
 		}
-
 		return
 	}
 
 	// Original single-package behavior
 	arg := flag.Arg(0)
-	if arg == "" {
-		log.Fatal("usage: goget <path> or goget --mod <path/to/go.mod>")
+	if arg == "" && !*depsFlag {
+		log.Fatal("usage: goget <path|url>, goget --mod <path/to/go.mod>, goget --unshallow <path>, goget mirror sync or goget status")
 	}
 
-	if _, err := runGoGet(ctx, arg, gopath, workingDir, *httpsFlag, *acceptSSHHostFlag, *skipFsckFlag); err != nil {
-		log.Fatal(err)
+	if arg != "" {
+		if _, err := runGoGet(ctx, arg, gopath, workingDir, opts); err != nil {
+			if hint := causeHint(errorCause(err), errorHosts([]error{err})); hint != "" {
+				log.Fatalf("%v\n%s", err, hint)
+			}
+			log.Fatal(err)
+		}
+	}
+
+	if *depsFlag {
+		results, err := runFetchImports(ctx, arg, gopath, workingDir, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(results) == 0 {
+			fmt.Println("All imports are already present in GOPATH")
+			return
+		}
+		if printSummary(results) {
+			os.Exit(1)
+		}
 	}
 }
@@ -126,6 +126,75 @@ func TestGetRepositoryURL(t *testing.T) {
 	}
 }
 
+func TestResolveRepository(t *testing.T) {
+	tests := []struct {
+		name         string
+		importPath   string
+		mockResponse string
+		expectedRoot string
+		expectedURL  string
+	}{
+		{
+			name:         "github subpackage",
+			importPath:   "github.com/user/repo/pkg/subpkg",
+			expectedRoot: "github.com/user/repo",
+			expectedURL:  "https://github.com/user/repo.git",
+		},
+		{
+			name:         "golang.org/x subpackage",
+			importPath:   "golang.org/x/crypto/ssh",
+			expectedRoot: "golang.org/x/crypto",
+			expectedURL:  "https://go.googlesource.com/crypto",
+		},
+		{
+			name:         "google.golang.org subpackage",
+			importPath:   "google.golang.org/grpc/codes",
+			expectedRoot: "google.golang.org/grpc",
+			expectedURL:  "https://github.com/googleapis/grpc",
+		},
+		{
+			name:       "discovered prefix is the root",
+			importPath: "example.com/pkg/subpkg",
+			mockResponse: `<!DOCTYPE html>
+<html>
+<head>
+	<meta name="go-import" content="example.com/pkg git https://github.com/example/pkg">
+</head>
+</html>`,
+			expectedRoot: "example.com/pkg",
+			expectedURL:  "https://github.com/example/pkg",
+		},
+		{
+			name:         "failed discovery uses the full path",
+			importPath:   "example.com/user/repo",
+			expectedRoot: "example.com/user/repo",
+			expectedURL:  "https://example.com/user/repo.git",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockHTTPClient{err: io.EOF}
+			if tt.mockResponse != "" {
+				client = &mockHTTPClient{
+					response: &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(strings.NewReader(tt.mockResponse)),
+					},
+				}
+			}
+
+			root, url := resolveRepository(tt.importPath, true, client)
+			if root != tt.expectedRoot {
+				t.Errorf("root = %q, want %q", root, tt.expectedRoot)
+			}
+			if url != tt.expectedURL {
+				t.Errorf("URL = %q, want %q", url, tt.expectedURL)
+			}
+		})
+	}
+}
+
 func TestParseImportPath(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -292,6 +361,38 @@ func TestBuildGitCommand(t *testing.T) {
 			expectedURL:  "https://github.com/user/repo.git",
 			expectedPath: "./repo",
 		},
+		{
+			name: "subpackage clones the repository root",
+			config: &Config{
+				GOPATH:     "/home/user/go",
+				ImportPath: "github.com/user/repo/sub/pkg",
+			},
+			useHTTPS:     true,
+			expectedURL:  "https://github.com/user/repo.git",
+			expectedPath: "/home/user/go/src/github.com/user/repo",
+		},
+		{
+			name: "golang.org/x subpackage clones the repository root",
+			config: &Config{
+				GOPATH:     "/home/user/go",
+				ImportPath: "golang.org/x/net/html",
+			},
+			useHTTPS:     false,
+			expectedURL:  "https://go.googlesource.com/net",
+			expectedPath: "/home/user/go/src/golang.org/x/net",
+		},
+		{
+			name: "explicit URL keeps the user's protocol",
+			config: &Config{
+				GOPATH:     "/home/user/go",
+				ImportPath: "github.com/user/repo/sub/pkg",
+				RepoURL:    "https://github.com/user/repo.git",
+				RepoRoot:   "github.com/user/repo",
+			},
+			useHTTPS:     false,
+			expectedURL:  "https://github.com/user/repo.git",
+			expectedPath: "/home/user/go/src/github.com/user/repo",
+		},
 	}
 
 	for _, tt := range tests {
@@ -341,6 +442,7 @@ func TestResolveConfig(t *testing.T) {
 		workingDir       string
 		expectedImport   string
 		expectedEllipsis bool
+		expectedVersion  string
 		expectError      bool
 	}{
 		{
@@ -359,6 +461,28 @@ func TestResolveConfig(t *testing.T) {
 			expectedImport:   "github.com/user/repo",
 			expectedEllipsis: true,
 		},
+		{
+			name:            "with version",
+			arg:             "github.com/user/repo@v1.2.3",
+			gopath:          "/home/user/go",
+			workingDir:      "/some/dir",
+			expectedImport:  "github.com/user/repo",
+			expectedVersion: "v1.2.3",
+		},
+		{
+			name:           "browser URL",
+			arg:            "https://github.com/user/repo/tree/main/sub",
+			gopath:         "/home/user/go",
+			workingDir:     "/some/dir",
+			expectedImport: "github.com/user/repo/sub",
+		},
+		{
+			name:           "SSH clone URL",
+			arg:            "git@github.com:user/repo.git",
+			gopath:         "/home/user/go",
+			workingDir:     "/some/dir",
+			expectedImport: "github.com/user/repo",
+		},
 		{
 			name:        "empty gopath",
 			arg:         "github.com/user/repo",
@@ -399,6 +523,10 @@ func TestResolveConfig(t *testing.T) {
 				t.Errorf("HasEllipsis = %v, want %v", config.HasEllipsis, tt.expectedEllipsis)
 			}
 
+			if config.Version != tt.expectedVersion {
+				t.Errorf("Version = %q, want %q", config.Version, tt.expectedVersion)
+			}
+
 			// Check that GOPATH was made absolute
 			if !filepath.IsAbs(config.GOPATH) {
 				t.Errorf("GOPATH should be absolute, got %q", config.GOPATH)
@@ -549,7 +677,7 @@ func TestParseGoImportMeta(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			vcs, url, err := parseGoImportMeta(strings.NewReader(tt.html), tt.importPath)
+			_, vcs, url, err := parseGoImportMeta(strings.NewReader(tt.html), tt.importPath)
 
 			if tt.expectError {
 				if err == nil {
@@ -657,7 +785,7 @@ func TestDiscoverGoImport(t *testing.T) {
 				}
 			}
 
-			vcs, url, err := discoverGoImport(tt.importPath, client)
+			_, vcs, url, err := discoverGoImport(tt.importPath, client)
 
 			if tt.expectError {
 				if err == nil {
@@ -824,6 +952,11 @@ func TestHttpsToSSH(t *testing.T) {
 			input:    "https://github.com",
 			expected: "https://github.com",
 		},
+		{
+			name:     "HTTPS port dropped",
+			input:    "https://git.example.com:8443/team/repo.git",
+			expected: "git@git.example.com:team/repo.git",
+		},
 		{
 			name:     "deep path",
 			input:    "https://example.com/org/suborg/repo.git",
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// mirrorCacheDir returns the directory holding the bare mirrors used by
+// --mirror and --offline
+func mirrorCacheDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "goget", "mirrors"), nil
+}
+
+// mirrorPath returns where the mirror of the repository at repoURL lives in
+// mirrorDir: <host>/<path>.git, the same for the SSH and HTTPS forms of a URL
+func mirrorPath(mirrorDir, repoURL string) (string, error) {
+	_, rest, ok := strings.Cut(sshToHTTPS(repoURL), "://")
+	if !ok {
+		return "", fmt.Errorf("cannot determine a mirror path for %s", repoURL)
+	}
+	if at := strings.Index(rest, "@"); at != -1 && at < strings.Index(rest+"/", "/") {
+		rest = rest[at+1:]
+	}
+	rest = strings.TrimSuffix(strings.Trim(rest, "/"), ".git")
+	if hostPort, path, ok := strings.Cut(rest, "/"); ok {
+		host, _, _ := strings.Cut(hostPort, ":")
+		rest = host + "/" + path
+	}
+	for elem := range strings.SplitSeq(rest, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("cannot determine a mirror path for %s", repoURL)
+		}
+	}
+	if rest == "" {
+		return "", fmt.Errorf("cannot determine a mirror path for %s", repoURL)
+	}
+	return filepath.Join(mirrorDir, filepath.FromSlash(rest)+".git"), nil
+}
+
+// importsFile is the file in the mirror cache that records the repository
+// each vanity import path resolved to
+const importsFile = "imports.json"
+
+// importCache is the mirror cache's record of the repositories vanity import
+// paths resolved to, keyed by the import path of the repository root. With
+// --offline it stands in for go-import discovery, which needs the network.
+type importCache struct {
+	path string
+	mu   sync.Mutex
+}
+
+// newImportCache returns the record of import paths in mirrorDir
+func newImportCache(mirrorDir string) *importCache {
+	return &importCache{path: filepath.Join(mirrorDir, importsFile)}
+}
+
+// read returns the recorded repository URL of each root
+func (c *importCache) read() (map[string]string, error) {
+	roots := make(map[string]string)
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return roots, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &roots); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", c.path, err)
+	}
+	return roots, nil
+}
+
+// lookup returns the recorded root of the repository containing importPath,
+// and its URL
+func (c *importCache) lookup(importPath string) (root, repoURL string, ok bool) {
+	if c == nil {
+		return "", "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	roots, err := c.read()
+	if err != nil {
+		log.Printf("WARN: %v", err)
+		return "", "", false
+	}
+	for r, url := range roots {
+		if (importPath == r || strings.HasPrefix(importPath, r+"/")) && len(r) > len(root) {
+			root, repoURL = r, url
+		}
+	}
+	return root, repoURL, root != ""
+}
+
+// record notes that root resolved to repoURL
+func (c *importCache) record(root, repoURL string) error {
+	if c == nil || root == "" || repoURL == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	roots, err := c.read()
+	if err != nil {
+		return err
+	}
+	if roots[root] == repoURL {
+		return nil
+	}
+	roots[root] = repoURL
+	data, err := json.MarshalIndent(roots, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	// Replace the file in one step, so other goget processes never read
+	// half of it
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), "."+importsFile+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// ensureMirror creates the bare mirror of repoURL at mirror, or fetches into
+// it if it already exists
+func ensureMirror(ctx context.Context, mirror, repoURL string, opts Options) error {
+	env := remoteEnv(ctx, nil, "", repoURL, opts)
+	if _, err := os.Stat(mirror); err == nil {
+		_, err := gitOutputEnv(ctx, mirror, env, "fetch", "--quiet", "--prune")
+		return err
+	}
+
+	// Like clones, mirrors are made in a temporary directory and moved into
+	// place, so an interrupted run never leaves a broken mirror behind
+	tmpDir, err := prepareTempDir(mirror)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if _, err := gitOutputEnv(ctx, "", env, "clone", "--quiet", "--mirror", repoURL, tmpDir); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpDir, mirror); err != nil {
+		if _, statErr := os.Stat(mirror); statErr == nil {
+			// Another clone in this run created it first
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// mirrorCloneArgs rewrites the clone arguments args to use the mirror of
+// repoURL: with --offline the clone is made from the mirror alone, otherwise
+// the mirror is refreshed and passed as a reference. It reports whether
+// origin has to be pointed back at repoURL after the clone.
+func mirrorCloneArgs(ctx context.Context, args []string, repoURL string, opts Options) (newArgs []string, resetOrigin bool, err error) {
+	mirror, err := mirrorPath(opts.MirrorDir, repoURL)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if opts.Offline {
+		if _, err := os.Stat(mirror); err != nil {
+			return nil, false, fmt.Errorf("%s is not in the mirror cache at %s (--offline)", repoURL, opts.MirrorDir)
+		}
+		i := slices.Index(args, repoURL)
+		if i == -1 {
+			return nil, false, fmt.Errorf("clone command does not include %s", repoURL)
+		}
+		args[i] = mirror
+		return args, true, nil
+	}
+
+	if err := ensureMirror(ctx, mirror, repoURL, opts); err != nil {
+		log.Printf("WARN: could not update mirror %s: %v", mirror, err)
+	}
+	refArgs := []string{"--reference-if-able", mirror}
+	if opts.Dissociate {
+		refArgs = append(refArgs, "--dissociate")
+	}
+	return slices.Insert(args, 1, refArgs...), false, nil
+}
+
+// resetCloneOrigin points the only remote of the clone in dir, origin or
+// upstream with --fork, at repoURL
+func resetCloneOrigin(ctx context.Context, dir, repoURL string) error {
+	remote, err := gitOutput(ctx, dir, "remote")
+	if err != nil {
+		return err
+	}
+	_, err = gitOutput(ctx, dir, "remote", "set-url", remote, repoURL)
+	return err
+}
+
+// findMirrors returns the bare repositories under mirrorDir
+func findMirrors(mirrorDir string) ([]string, error) {
+	var mirrors []string
+	err := filepath.WalkDir(mirrorDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == mirrorDir && os.IsNotExist(err) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() && strings.HasSuffix(d.Name(), ".git") && !strings.HasPrefix(d.Name(), ".") {
+			mirrors = append(mirrors, path)
+			return fs.SkipDir
+		}
+		return nil
+	})
+	return mirrors, err
+}
+
+// syncMirrors fetches every mirror under mirrorDir, up to maxParallel at a
+// time, and returns the ones that failed
+func syncMirrors(ctx context.Context, mirrorDir string, opts Options) (map[string]error, error) {
+	mirrors, err := findMirrors(mirrorDir)
+	if err != nil {
+		return nil, err
+	}
+
+	failed := make(map[string]error)
+	var mu sync.Mutex
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxParallel)
+	for _, mirror := range mirrors {
+		g.Go(func() error {
+			env := remoteEnv(ctx, nil, mirror, repoRemoteURL(ctx, mirror), opts)
+			_, err := gitOutputEnv(ctx, mirror, env, "fetch", "--quiet", "--prune")
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed[mirror] = err
+				fmt.Printf("ERROR: %s: %v\n", mirror, err)
+			} else {
+				fmt.Printf("Synced %s\n", mirror)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait() // errors are recorded per mirror
+	fmt.Printf("Synced %d of %d mirrors in %s\n", len(mirrors)-len(failed), len(mirrors), mirrorDir)
+	return failed, nil
+}
+
+// runMirror implements "goget mirror sync"
+func runMirror(ctx context.Context, args []string, opts Options) error {
+	if len(args) != 1 || args[0] != "sync" {
+		return fmt.Errorf("usage: goget mirror sync")
+	}
+	failed, err := syncMirrors(ctx, opts.MirrorDir, opts)
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d mirrors failed to sync", len(failed))
+	}
+	return nil
+}
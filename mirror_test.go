@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMirrorPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		expected    string
+		expectError bool
+	}{
+		{"https", "https://github.com/user/repo.git", "/cache/github.com/user/repo.git", false},
+		{"https without .git", "https://github.com/user/repo", "/cache/github.com/user/repo.git", false},
+		{"scp-like SSH", "git@github.com:user/repo.git", "/cache/github.com/user/repo.git", false},
+		{"ssh with port", "ssh://git@git.example.com:2222/team/repo.git", "/cache/git.example.com/team/repo.git", false},
+		{"file", "file:///srv/git/repo.git", "/cache/srv/git/repo.git", false},
+		{"parent directory", "https://example.com/../repo", "", true},
+		{"not a URL", "/srv/git/repo", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mirrorPath("/cache", tt.url)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("mirrorPath() error = %v, expectError %v", err, tt.expectError)
+			}
+			if got != filepath.FromSlash(tt.expected) {
+				t.Errorf("mirrorPath() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMirrorClone(t *testing.T) {
+	ctx := context.Background()
+	upstream := filepath.Join(t.TempDir(), "upstream")
+	initTestRepo(t, upstream)
+	url := "file://" + upstream
+	mirrorDir := t.TempDir()
+
+	clone := func(t *testing.T, opts Options) (string, error) {
+		target := filepath.Join(t.TempDir(), "repo")
+		cmd := &GitCommand{URL: url, TargetPath: target, Args: []string{"clone", "--quiet", url, target}}
+		_, err := executeGitCommand(ctx, cmd, opts)
+		return target, err
+	}
+
+	// Offline before anything has been mirrored
+	if _, err := clone(t, Options{MirrorDir: mirrorDir, Offline: true}); err == nil {
+		t.Fatal("offline clone without a mirror succeeded")
+	}
+
+	target, err := clone(t, Options{MirrorDir: mirrorDir})
+	if err != nil {
+		t.Fatalf("clone with mirror: %v", err)
+	}
+	mirror, _ := mirrorPath(mirrorDir, url)
+	if _, err := os.Stat(filepath.Join(mirror, "HEAD")); err != nil {
+		t.Errorf("mirror not created: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, ".git", "objects", "info", "alternates")); err != nil {
+		t.Errorf("clone does not borrow objects from the mirror: %v", err)
+	}
+
+	target, err = clone(t, Options{MirrorDir: mirrorDir, Dissociate: true})
+	if err != nil {
+		t.Fatalf("clone with --dissociate: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, ".git", "objects", "info", "alternates")); err == nil {
+		t.Error("dissociated clone still borrows objects from the mirror")
+	}
+
+	// A new upstream commit reaches the mirror with mirror sync
+	commitFile(t, upstream, "new.go", "package repo\n")
+	if failed, err := syncMirrors(ctx, mirrorDir, Options{}); err != nil || len(failed) != 0 {
+		t.Fatalf("syncMirrors() = %v, %v", failed, err)
+	}
+
+	// With the upstream gone, offline clones still work from the mirror
+	if err := os.RemoveAll(upstream); err != nil {
+		t.Fatal(err)
+	}
+	target, err = clone(t, Options{MirrorDir: mirrorDir, Offline: true})
+	if err != nil {
+		t.Fatalf("offline clone: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "new.go")); err != nil {
+		t.Error("offline clone is missing the synced commit")
+	}
+	origin, err := gitOutput(ctx, target, "remote", "get-url", "origin")
+	if err != nil || origin != url {
+		t.Errorf("origin = %q, %v, want %q", origin, err, url)
+	}
+}
+
+// offlineClient fails the test if go-import discovery uses the network
+type offlineClient struct{ t *testing.T }
+
+func (c offlineClient) Get(url string) (*http.Response, error) {
+	c.t.Errorf("GET %s with --offline", url)
+	return nil, errors.New("offline")
+}
+
+func TestOfflineImportPaths(t *testing.T) {
+	imports := newImportCache(t.TempDir())
+	online := &Config{Imports: imports, Client: &mockHTTPClient{response: &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`<meta name="go-import" content="example.com/pkg git https://git.example.com/pkg.git">`)),
+	}}}
+	if root, url := online.resolveRepository("example.com/pkg/sub", true); root != "example.com/pkg" || url != "https://git.example.com/pkg.git" {
+		t.Fatalf("resolveRepository() = %q, %q; want the discovered repository", root, url)
+	}
+
+	offline := &Config{Imports: imports, Offline: true, Client: offlineClient{t}}
+	tests := []struct {
+		importPath   string
+		useHTTPS     bool
+		expectedRoot string
+		expectedURL  string
+	}{
+		{"example.com/pkg/sub", true, "example.com/pkg", "https://git.example.com/pkg.git"},
+		{"example.com/pkg", false, "example.com/pkg", "git@git.example.com:pkg.git"},
+		// Not recorded, so guessed
+		{"example.com/other", true, "example.com/other", "https://example.com/other.git"},
+	}
+	for _, tt := range tests {
+		root, url := offline.resolveRepository(tt.importPath, tt.useHTTPS)
+		if root != tt.expectedRoot || url != tt.expectedURL {
+			t.Errorf("resolveRepository(%q, %t) = %q, %q; want %q, %q", tt.importPath, tt.useHTTPS, root, url, tt.expectedRoot, tt.expectedURL)
+		}
+	}
+}
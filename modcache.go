@@ -0,0 +1,54 @@
+package main
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// modCacheVCSDir returns the directory where the go command keeps bare
+// clones of the repositories it downloads modules from:
+// $GOMODCACHE/cache/vcs, with GOMODCACHE defaulting to $GOPATH/pkg/mod
+func modCacheVCSDir(gopath string) string {
+	modCache := os.Getenv("GOMODCACHE")
+	if modCache == "" {
+		if gopath == "" {
+			gopath = build.Default.GOPATH
+		}
+		if gopath == "" {
+			return ""
+		}
+		modCache = filepath.Join(firstGOPATH(gopath), "pkg", "mod")
+	}
+	return filepath.Join(modCache, "cache", "vcs")
+}
+
+// findModCacheRepo returns the bare repository in vcsDir the go command
+// cloned from repoURL, or "" if there is none. Each repository is named for
+// the SHA-256 of a key like "git3:https://github.com/user/repo", which is
+// also written to <dir>.info; older Go versions used other git key types.
+func findModCacheRepo(vcsDir, repoURL string) string {
+	if vcsDir == "" {
+		return ""
+	}
+	infos, err := filepath.Glob(filepath.Join(vcsDir, "*.info"))
+	if err != nil {
+		return ""
+	}
+	for _, info := range infos {
+		data, err := os.ReadFile(info)
+		if err != nil {
+			continue
+		}
+		typ, remote, ok := strings.Cut(strings.TrimSpace(string(data)), ":")
+		if !ok || !strings.HasPrefix(typ, "git") || !sameRemote(remote, repoURL) {
+			continue
+		}
+		dir := strings.TrimSuffix(info, ".info")
+		if _, err := os.Stat(filepath.Join(dir, "objects")); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
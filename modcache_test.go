@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// addModCacheRepo makes a bare clone of upstream in vcsDir, named and
+// described the way the go command does it
+func addModCacheRepo(t *testing.T, vcsDir, typ, remote, upstream string) string {
+	t.Helper()
+	key := typ + ":" + remote
+	dir := filepath.Join(vcsDir, fmt.Sprintf("%x", sha256.Sum256([]byte(key))))
+	if _, err := gitOutput(context.Background(), "", "clone", "--quiet", "--bare", upstream, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+".info", []byte(key+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestFindModCacheRepo(t *testing.T) {
+	upstream := filepath.Join(t.TempDir(), "upstream")
+	initTestRepo(t, upstream)
+	vcsDir := t.TempDir()
+	git3 := addModCacheRepo(t, vcsDir, "git3", "https://github.com/user/repo", upstream)
+	git2 := addModCacheRepo(t, vcsDir, "git2", "https://github.com/user/old", upstream)
+	addModCacheRepo(t, vcsDir, "hg", "https://example.com/hg/repo", upstream)
+
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{"https", "https://github.com/user/repo.git", git3},
+		{"ssh", "git@github.com:user/repo.git", git3},
+		{"older key type", "git@github.com:user/old.git", git2},
+		{"not git", "https://example.com/hg/repo", ""},
+		{"not cached", "git@github.com:user/other.git", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findModCacheRepo(vcsDir, tt.url); got != tt.expected {
+				t.Errorf("findModCacheRepo() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if got := findModCacheRepo("", "https://github.com/user/repo"); got != "" {
+		t.Errorf("findModCacheRepo() with no cache = %q, want \"\"", got)
+	}
+}
+
+func TestModCacheSeededClone(t *testing.T) {
+	ctx := context.Background()
+	upstream := filepath.Join(t.TempDir(), "upstream")
+	initTestRepo(t, upstream)
+	url := "file://" + upstream
+	vcsDir := t.TempDir()
+	addModCacheRepo(t, vcsDir, "git3", url, upstream)
+
+	target := filepath.Join(t.TempDir(), "repo")
+	cmd := &GitCommand{URL: url, TargetPath: target, Args: []string{"clone", "--quiet", url, target}}
+	if _, err := executeGitCommand(ctx, cmd, Options{ModCacheVCS: vcsDir}); err != nil {
+		t.Fatalf("executeGitCommand() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, ".git", "objects", "info", "alternates")); err == nil {
+		t.Error("clone depends on the module cache")
+	}
+	origin, err := gitOutput(ctx, target, "remote", "get-url", "origin")
+	if err != nil || origin != url {
+		t.Errorf("origin = %q, %v, want %q", origin, err, url)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RemoteInfo is what a remote advertises without cloning it
+type RemoteInfo struct {
+	DefaultBranch string   // branch HEAD points at; "" for an empty repository
+	Tags          []string // tag names, without refs/tags/, sorted
+}
+
+// parseLsRemote parses the output of "git ls-remote --symref"
+func parseLsRemote(out string) RemoteInfo {
+	var info RemoteInfo
+	for line := range strings.SplitSeq(out, "\n") {
+		value, ref, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		if target, ok := strings.CutPrefix(value, "ref: "); ok && ref == "HEAD" {
+			info.DefaultBranch = strings.TrimPrefix(target, "refs/heads/")
+		} else if tag, ok := strings.CutPrefix(ref, "refs/tags/"); ok && !strings.HasSuffix(tag, "^{}") {
+			info.Tags = append(info.Tags, tag)
+		}
+	}
+	slices.Sort(info.Tags)
+	return info
+}
+
+// preflight checks that cmd.URL can be reached before cloning from it, so a
+// candidate that can't be cloned fails fast, with the cause of the failure.
+// Only --check reports what the remote advertises; a clone learns it anyway.
+// It does nothing with --offline, where the clone does not use the network.
+func preflight(ctx context.Context, cmd *GitCommand, opts Options) error {
+	if opts.Offline {
+		return nil
+	}
+	_, err := opts.cloner().ListRemote(ctx, cmd.URL, opts)
+	return err
+}
+
+// describeRemote summarizes info for --check
+func describeRemote(info RemoteInfo) string {
+	if info.DefaultBranch == "" {
+		return "empty repository"
+	}
+	tags := fmt.Sprintf("%d tags", len(info.Tags))
+	if len(info.Tags) == 1 {
+		tags = "1 tag"
+	}
+	return fmt.Sprintf("default branch %s, %s", info.DefaultBranch, tags)
+}
+
+// checkCandidates is --check's counterpart to cloning: it lists each
+// candidate's refs in turn, under the retry policy, until one can be
+// reached, and reports which one that is, without cloning anything
+func checkCandidates(ctx context.Context, candidates []cloneCandidate, opts Options, result *DependencyResult) error {
+	_, _, err := tryCandidates(candidates, result, func(cmd *GitCommand) (bool, error) {
+		fmt.Printf("git ls-remote %s\n", cmd.URL)
+		var info RemoteInfo
+		retries, err := opts.Retry.retry(ctx, "ls-remote of "+cmd.URL, func() (err error) {
+			info, err = opts.cloner().ListRemote(ctx, cmd.URL, opts)
+			return err
+		})
+		result.Retries += retries
+		if err == nil {
+			fmt.Printf("Reached %s: %s\n", cmd.URL, describeRemote(info))
+		}
+		return false, err
+	})
+	return err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseLsRemote(t *testing.T) {
+	out := "ref: refs/heads/main\tHEAD\n" +
+		"aba17cec03778a49c29f868732e37bf52242a1e2\tHEAD\n" +
+		"07337def87c86ae7126faa30fa19bd8fa3c76e16\trefs/tags/v1.1.0\n" +
+		"aba17cec03778a49c29f868732e37bf52242a1e2\trefs/tags/v1.1.0^{}\n" +
+		"aba17cec03778a49c29f868732e37bf52242a1e2\trefs/tags/v1.0.0\n"
+	info := parseLsRemote(out)
+	if info.DefaultBranch != "main" {
+		t.Errorf("DefaultBranch = %q, want main", info.DefaultBranch)
+	}
+	if want := []string{"v1.0.0", "v1.1.0"}; !slices.Equal(info.Tags, want) {
+		t.Errorf("Tags = %v, want %v", info.Tags, want)
+	}
+	if info := parseLsRemote(""); info.DefaultBranch != "" || len(info.Tags) != 0 {
+		t.Errorf("parseLsRemote(empty) = %+v, want zero value", info)
+	}
+}
+
+func TestListRemote(t *testing.T) {
+	isolateGitConfig(t)
+	repo := filepath.Join(t.TempDir(), "repo")
+	initTestRepo(t, repo, "v1.0.0", "v1.1.0")
+
+	for _, cloner := range []Cloner{execCloner{}, goGitCloner{}} {
+		info, err := cloner.ListRemote(context.Background(), repo, Options{})
+		if err != nil {
+			t.Fatalf("%T: %v", cloner, err)
+		}
+		if info.DefaultBranch != "main" || !slices.Equal(info.Tags, []string{"v1.0.0", "v1.1.0"}) {
+			t.Errorf("%T: ListRemote = %+v, want main and two tags", cloner, info)
+		}
+
+		var gitErr *GitError
+		if _, err := cloner.ListRemote(context.Background(), filepath.Join(t.TempDir(), "missing"), Options{}); !errors.As(err, &gitErr) {
+			t.Errorf("%T: ListRemote(missing) = %v, want a *GitError", cloner, err)
+		}
+	}
+}
+
+func TestRunGoGetPreflight(t *testing.T) {
+	noSleep(t)
+	mirrors := &UserConfig{Hosts: map[string]HostConfig{
+		"github.com": {Mirrors: []string{"https://gitea.internal/github"}},
+	}}
+	repos := map[string]map[string]string{
+		"https://gitea.internal/github/user/repo.git": {"go.mod": "module github.com/user/repo\n"},
+		"https://github.com/user/repo.git":            {"go.mod": "module github.com/user/repo\n"},
+	}
+
+	t.Run("unreachable candidate is not cloned", func(t *testing.T) {
+		gopath := t.TempDir()
+		fake := &fakeCloner{repos: repos, unreachable: map[string]bool{"https://gitea.internal/github/user/repo.git": true}}
+		opts := Options{HTTPS: true, UserConfig: mirrors, Cloner: fake, Retry: RetryPolicy{MaxAttempts: 1}}
+		result, err := runGoGet(context.Background(), "github.com/user/repo", gopath, gopath, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"https://gitea.internal/github/user/repo.git", "https://github.com/user/repo.git"}; !slices.Equal(fake.lists, want) {
+			t.Errorf("lists = %v, want %v", fake.lists, want)
+		}
+		if want := []string{"https://github.com/user/repo.git"}; !slices.Equal(fake.clones, want) {
+			t.Errorf("clones = %v, want %v", fake.clones, want)
+		}
+		if result.Source != sourceUpstream {
+			t.Errorf("Source = %q, want %q", result.Source, sourceUpstream)
+		}
+	})
+
+	t.Run("check clones nothing", func(t *testing.T) {
+		gopath := t.TempDir()
+		fake := &fakeCloner{repos: repos, unreachable: map[string]bool{"https://gitea.internal/github/user/repo.git": true}}
+		opts := Options{HTTPS: true, UserConfig: mirrors, Cloner: fake, Retry: RetryPolicy{MaxAttempts: 1}, Check: true}
+		result, err := runGoGet(context.Background(), "github.com/user/repo", gopath, gopath, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fake.clones) != 0 {
+			t.Errorf("clones = %v, want none", fake.clones)
+		}
+		if result.Source != sourceUpstream {
+			t.Errorf("Source = %q, want %q", result.Source, sourceUpstream)
+		}
+		if _, err := os.Stat(filepath.Join(gopath, "src", "github.com", "user", "repo")); !os.IsNotExist(err) {
+			t.Errorf("--check created the clone directory: %v", err)
+		}
+	})
+
+	t.Run("check reports the cause", func(t *testing.T) {
+		gopath := t.TempDir()
+		fake := &fakeCloner{repos: repos, unreachable: map[string]bool{
+			"https://gitea.internal/github/user/repo.git": true,
+			"https://github.com/user/repo.git":            true,
+		}}
+		opts := Options{HTTPS: true, UserConfig: mirrors, Cloner: fake, Retry: RetryPolicy{MaxAttempts: 1}, Check: true}
+		_, err := runGoGet(context.Background(), "github.com/user/repo", gopath, gopath, opts)
+		if !errors.Is(err, ErrNetwork) {
+			t.Errorf("err = %v, want ErrNetwork", err)
+		}
+	})
+}
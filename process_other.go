@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+// processAlive reports whether a process with the given PID is running. We
+// have no cheap way to tell here, so assume it is, and leave its temporary
+// directories alone.
+func processAlive(pid int) bool {
+	return true
+}
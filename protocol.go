@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Values of HostConfig.Protocol
+const (
+	protocolSSH   = "ssh"
+	protocolHTTPS = "https"
+)
+
+// protocolMemory remembers the hosts where SSH failed and HTTPS worked
+// during a run, so later clones from them skip the SSH attempt. Hosts are
+// keyed together with the identity file goget's configuration names for the
+// repository, since a different key may well work.
+type protocolMemory struct {
+	mu    sync.Mutex
+	https map[string]bool
+}
+
+// protocolKey returns the key for the host and SSH identity of repoURL
+func protocolKey(repoURL string, opts Options) string {
+	return strings.ToLower(extractHostFromGitURL(repoURL)) + "\x00" + opts.UserConfig.host(repoURL).IdentityFile
+}
+
+// rememberHTTPS records that cloning sshURL over SSH failed, and its HTTPS
+// fallback worked
+func (m *protocolMemory) rememberHTTPS(sshURL string, opts Options) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.https == nil {
+		m.https = make(map[string]bool)
+	}
+	m.https[protocolKey(sshURL, opts)] = true
+}
+
+// usesHTTPS reports whether SSH already failed for the host of sshURL
+func (m *protocolMemory) usesHTTPS(sshURL string, opts Options) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.https[protocolKey(sshURL, opts)]
+}
+
+// preferHTTPS reports whether to clone sshURL over HTTPS straight away, and
+// why: goget's configuration asks for HTTPS for it, or SSH to its host
+// already failed during this run. A configured "ssh" always tries SSH first.
+// remembered is set in the second case, where SSH remains worth a try if
+// HTTPS fails, since the repository may be private.
+func preferHTTPS(sshURL string, opts Options) (https, remembered bool, reason string) {
+	switch opts.UserConfig.host(sshURL).Protocol {
+	case protocolHTTPS:
+		return true, false, "configured for this host"
+	case protocolSSH:
+		return false, false, ""
+	}
+	if opts.Protocols.usesHTTPS(sshURL, opts) {
+		return true, true, "SSH to this host failed earlier in this run"
+	}
+	return false, false, ""
+}
+
+// sshRetryWorthwhile reports whether a clone over HTTPS that failed with err
+// might work over SSH: HTTPS has no credentials for a private repository,
+// which some hosts report as missing
+func sshRetryWorthwhile(err error) bool {
+	switch errorCause(err) {
+	case nil, ErrAuthDenied, ErrNotFound:
+		return true
+	}
+	return false
+}
+
+// validProtocol checks a HostConfig.Protocol value
+func validProtocol(protocol string) error {
+	switch protocol {
+	case "", protocolSSH, protocolHTTPS:
+		return nil
+	}
+	return fmt.Errorf("unknown protocol %q: must be %q or %q", protocol, protocolSSH, protocolHTTPS)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestPreferHTTPS(t *testing.T) {
+	config := &UserConfig{Hosts: map[string]HostConfig{
+		"github.com":      {Protocol: "https"},
+		"github.com/acme": {Protocol: "ssh"},
+		"gitlab.com/team": {IdentityFile: "/keys/team"},
+	}}
+	memory := &protocolMemory{}
+	opts := Options{UserConfig: config, Protocols: memory}
+	memory.rememberHTTPS("git@github.com:acme/old.git", opts)
+	memory.rememberHTTPS("git@gitlab.com:other/repo.git", opts)
+
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"git@github.com:user/repo.git", true},
+		{"git@github.com:acme/repo.git", false},
+		{"git@gitlab.com:user/repo.git", true},
+		// A different identity file might work where the default key failed
+		{"git@gitlab.com:team/repo.git", false},
+		{"git@bitbucket.org:user/repo.git", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got, _, _ := preferHTTPS(tt.url, opts); got != tt.expected {
+				t.Errorf("preferHTTPS(%q) = %t, want %t", tt.url, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRunGoGetProtocols(t *testing.T) {
+	repos := map[string]map[string]string{
+		"https://github.com/user/one.git":       {"go.mod": "module github.com/user/one\n"},
+		"https://github.com/user/two.git":       {"go.mod": "module github.com/user/two\n"},
+		"git@github.com:acme/three.git":         {"go.mod": "module github.com/acme/three\n"},
+		"ssh://git@git.acme.internal/three.git": {"go.mod": "module github.com/acme/three\n"},
+	}
+	tests := []struct {
+		name         string
+		config       *UserConfig
+		rewrites     []urlRewrite
+		expectClones []string
+	}{
+		{
+			name: "HTTPS fallback is remembered for the host",
+			expectClones: []string{
+				"git@github.com:user/one.git", "https://github.com/user/one.git",
+				"https://github.com/user/two.git",
+				"https://github.com/acme/three.git", "git@github.com:acme/three.git",
+			},
+		},
+		{
+			name: "configured protocols",
+			config: &UserConfig{Hosts: map[string]HostConfig{
+				"github.com":      {Protocol: "https"},
+				"github.com/acme": {Protocol: "ssh"},
+			}},
+			expectClones: []string{
+				"https://github.com/user/one.git",
+				"https://github.com/user/two.git",
+				"git@github.com:acme/three.git",
+			},
+		},
+		{
+			name: "protocol of the host a rule rewrites to",
+			config: &UserConfig{Hosts: map[string]HostConfig{
+				"github.com": {Protocol: "https"},
+			}},
+			rewrites: []urlRewrite{{Base: "ssh://git@git.acme.internal/", Prefix: "git@github.com:acme/"}},
+			expectClones: []string{
+				"https://github.com/user/one.git",
+				"https://github.com/user/two.git",
+				"ssh://git@git.acme.internal/three.git",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gopath := t.TempDir()
+			fake := &fakeCloner{repos: repos}
+			opts := Options{Cloner: fake, UserConfig: tt.config, GitURLRewrites: tt.rewrites, Protocols: &protocolMemory{}}
+			for _, arg := range []string{"github.com/user/one", "github.com/user/two", "github.com/acme/three"} {
+				if _, err := runGoGet(context.Background(), arg, gopath, gopath, opts); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !slices.Equal(fake.clones, tt.expectClones) {
+				t.Errorf("clones = %v, want %v", fake.clones, tt.expectClones)
+			}
+		})
+	}
+}
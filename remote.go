@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// oldRemoteName is the remote --fix-remotes keeps the previous origin URL
+// under
+const oldRemoteName = "origin-old"
+
+// verifyRemote checks that the origin remote of the existing clone in dir
+// points at expectedURL, treating SSH and HTTPS forms as the same. If fix is
+// true, a mismatched origin is rewritten to expectedURL, and the old URL is
+// kept as the origin-old remote. A clone set up by --fork, whose upstream
+// remote points at expectedURL, is left alone. It reports whether origin
+// pointed somewhere else.
+func verifyRemote(ctx context.Context, dir, expectedURL string, fix bool) (mismatch bool, err error) {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		// A module inside a larger repository; its origin belongs to the
+		// parent
+		return false, nil
+	}
+
+	originURL, err := gitOutput(ctx, dir, "remote", "get-url", "origin")
+	if err != nil {
+		if !fix {
+			log.Printf("WARN: %s has no origin remote, expected %s (use --fix-remotes to add it)", dir, expectedURL)
+			return true, nil
+		}
+		if _, err := gitOutput(ctx, dir, "remote", "add", "origin", expectedURL); err != nil {
+			return true, err
+		}
+		fmt.Printf("Added origin remote %s to %s\n", expectedURL, dir)
+		return true, nil
+	}
+
+	if sameRemote(originURL, expectedURL) {
+		return false, nil
+	}
+	// A --fork clone: origin is the user's fork, and the canonical
+	// repository is the upstream remote
+	if upstreamURL, err := gitOutput(ctx, dir, "remote", "get-url", upstreamRemoteName); err == nil && sameRemote(upstreamURL, expectedURL) {
+		return false, nil
+	}
+
+	if !fix {
+		log.Printf("WARN: origin of %s is %s, expected %s (use --fix-remotes to rewrite it)", dir, originURL, expectedURL)
+		return true, nil
+	}
+
+	if _, err := gitOutput(ctx, dir, "remote", "get-url", oldRemoteName); err == nil {
+		return true, fmt.Errorf("cannot fix origin of %s: remote %s already exists", dir, oldRemoteName)
+	}
+	if _, err := gitOutput(ctx, dir, "remote", "add", oldRemoteName, originURL); err != nil {
+		return true, err
+	}
+	if _, err := gitOutput(ctx, dir, "remote", "set-url", "origin", expectedURL); err != nil {
+		return true, err
+	}
+	fmt.Printf("Rewrote origin of %s from %s to %s (old URL kept as %s)\n", dir, originURL, expectedURL, oldRemoteName)
+	return true, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSSHToHTTPS(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "scp-style SSH URL",
+			input:    "git@github.com:user/repo.git",
+			expected: "https://github.com/user/repo.git",
+		},
+		{
+			name:     "scp-style SSH URL without .git suffix",
+			input:    "git@github.com:user/repo",
+			expected: "https://github.com/user/repo.git",
+		},
+		{
+			name:     "ssh URL with port",
+			input:    "ssh://git@host.example.com:2222/team/repo.git",
+			expected: "https://host.example.com/team/repo.git",
+		},
+		{
+			name:     "git+ssh URL with port",
+			input:    "git+ssh://git@host.example.com:2222/team/repo.git",
+			expected: "https://host.example.com/team/repo.git",
+		},
+		{
+			name:     "git+ssh URL without a path unchanged",
+			input:    "git+ssh://git@host.example.com",
+			expected: "git+ssh://git@host.example.com",
+		},
+		{
+			name:     "HTTPS URL unchanged",
+			input:    "https://github.com/user/repo.git",
+			expected: "https://github.com/user/repo.git",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sshToHTTPS(tt.input); got != tt.expected {
+				t.Errorf("sshToHTTPS(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+			// Round trip through httpsToSSH
+			if isSSHURL(tt.input) && !strings.Contains(tt.input, "://") {
+				if got := httpsToSSH(sshToHTTPS(tt.input)); !sameRemote(got, tt.input) {
+					t.Errorf("httpsToSSH(sshToHTTPS(%q)) = %q", tt.input, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSameRemote(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"git@github.com:user/repo.git", "https://github.com/user/repo", true},
+		{"https://GitHub.com/user/repo.git/", "https://github.com/user/repo.git", true},
+		{"ssh://git@github.com/user/repo.git", "git@github.com:user/repo.git", true},
+		{"git+ssh://git@github.com/user/repo.git", "https://github.com/user/repo", true},
+		{"git+ssh://git@github.com", "https://github.com/user/repo", false},
+		{"git@github.com:user/repo.git", "git@github.com:fork/repo.git", false},
+		{"https://github.com/user/repo.git", "https://gitlab.com/user/repo.git", false},
+		{"https://github.com/user/Repo.git", "https://github.com/user/repo.git", false},
+	}
+
+	for _, tt := range tests {
+		if got := sameRemote(tt.a, tt.b); got != tt.expected {
+			t.Errorf("sameRemote(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestVerifyRemote(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "repo")
+	initTestRepo(t, dir)
+	if _, err := gitOutput(ctx, dir, "remote", "add", "origin", "https://github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	}
+
+	mismatch, err := verifyRemote(ctx, dir, "git@github.com:user/repo.git", false)
+	if err != nil || mismatch {
+		t.Errorf("verifyRemote() = %v, %v; want no mismatch for SSH and HTTPS forms", mismatch, err)
+	}
+
+	mismatch, err = verifyRemote(ctx, dir, "git@github.com:other/repo.git", false)
+	if err != nil || !mismatch {
+		t.Errorf("verifyRemote() = %v, %v; want mismatch", mismatch, err)
+	}
+	if origin, _ := gitOutput(ctx, dir, "remote", "get-url", "origin"); origin != "https://github.com/user/repo" {
+		t.Errorf("origin changed without --fix-remotes: %q", origin)
+	}
+
+	mismatch, err = verifyRemote(ctx, dir, "git@github.com:other/repo.git", true)
+	if err != nil || !mismatch {
+		t.Fatalf("verifyRemote() = %v, %v; want mismatch", mismatch, err)
+	}
+	if origin, _ := gitOutput(ctx, dir, "remote", "get-url", "origin"); origin != "git@github.com:other/repo.git" {
+		t.Errorf("origin = %q, want the expected URL", origin)
+	}
+	if old, _ := gitOutput(ctx, dir, "remote", "get-url", oldRemoteName); old != "https://github.com/user/repo" {
+		t.Errorf("%s = %q, want the old origin URL", oldRemoteName, old)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Defaults for --max-attempts and --retry-deadline
+const (
+	defaultMaxAttempts   = 3
+	defaultRetryDeadline = 5 * time.Minute
+)
+
+// RetryPolicy bounds how often a failed clone or go-import lookup is retried
+type RetryPolicy struct {
+	MaxAttempts int           // attempts, including the first; 1 or less disables retries
+	Deadline    time.Duration // no retry starts this long after the first attempt; 0 means no limit
+}
+
+// backoff is the delay before retrying an error of one class: it doubles
+// with each attempt from Base up to Max
+type backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// retryBackoffs lists the error classes worth retrying. Network failures
+// are usually over in a moment; a forge returning 5xx errors gets more time
+// to recover. Anything else fails the same way on every attempt.
+var retryBackoffs = map[error]backoff{
+	ErrNetwork: {Base: time.Second, Max: 30 * time.Second},
+	ErrServer:  {Base: 2 * time.Second, Max: time.Minute},
+}
+
+// sleep waits for d or until ctx is done. Tests replace it to run retries
+// without waiting.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// delay returns how long to wait before attempt+1, after attempt attempts
+// have failed: the backoff for the attempt, less up to half of it at random
+// so parallel clones of one host don't retry in lockstep
+func (b backoff) delay(attempt int) time.Duration {
+	d := b.Max
+	if shift := attempt - 1; shift < 30 && b.Base<<shift < b.Max {
+		d = b.Base << shift
+	}
+	return d - rand.N(d/2+1)
+}
+
+// retry runs fn until it succeeds, fails with an error that is not worth
+// retrying, or the policy's attempts or deadline run out, and returns the
+// number of retries along with fn's last error. what names the operation in
+// log messages.
+func (p RetryPolicy) retry(ctx context.Context, what string, fn func() error) (retries int, err error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil {
+			return retries, err
+		}
+		b, ok := retryBackoffs[errorCause(err)]
+		if !ok {
+			return retries, err
+		}
+		d := b.delay(attempt)
+		if p.Deadline > 0 && time.Since(start)+d > p.Deadline {
+			return retries, err
+		}
+		log.Printf("%s failed (%s), retrying in %s (attempt %d of %d)", what, causeName(err), d.Round(time.Millisecond), attempt+1, p.MaxAttempts)
+		if sleep(ctx, d) != nil {
+			return retries, err
+		}
+		retries++
+	}
+}
+
+// httpDoer sends HTTP requests, like *http.Client
+type httpDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// retryingClient is an HTTPClient for go-import discovery that retries
+// requests failing with a network error or a 5xx status, and counts the
+// retries it made. Requests are cancelled with ctx.
+type retryingClient struct {
+	ctx     context.Context
+	client  httpDoer
+	policy  RetryPolicy
+	retries int
+}
+
+func (c *retryingClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp *http.Response
+	retries, err := c.policy.retry(c.ctx, "GET "+url, func() error {
+		var err error
+		resp, err = c.client.Do(req)
+		if err != nil {
+			switch {
+			case c.ctx.Err() != nil:
+				return err
+			case isTLSError(err):
+				// A certificate that fails to verify fails again
+				return fmt.Errorf("%w: %w", ErrTLS, err)
+			}
+			return fmt.Errorf("%w: %w", ErrNetwork, err)
+		}
+		if resp.StatusCode >= 500 {
+			resp.Body.Close()
+			return fmt.Errorf("%w: got status %d from %s", ErrServer, resp.StatusCode, url)
+		}
+		return nil
+	})
+	c.retries += retries
+	if err != nil {
+		// Keep discoverGoImport's own messages for failures it reports
+		if errors.Is(err, ErrServer) {
+			return resp, nil
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// printRetrySummary lists the dependencies that needed retries
+func printRetrySummary(results []DependencyResult) {
+	var retried []DependencyResult
+	for _, result := range results {
+		if result.Retries > 0 {
+			retried = append(retried, result)
+		}
+	}
+	if len(retried) == 0 {
+		return
+	}
+	fmt.Println("\nRetried:")
+	for _, result := range retried {
+		retries := fmt.Sprintf("%d retries", result.Retries)
+		if result.Retries == 1 {
+			retries = "1 retry"
+		}
+		status := "succeeded"
+		if result.Error != nil {
+			status = "failed"
+		}
+		fmt.Printf("  - %s: %s, %s\n", result.ImportPath, retries, status)
+	}
+}
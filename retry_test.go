@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// noSleep makes retries run without waiting for the duration of the test,
+// recording the delays they asked for
+func noSleep(t *testing.T) *[]time.Duration {
+	var delays []time.Duration
+	old := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { sleep = old })
+	return &delays
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := backoff{Base: time.Second, Max: 10 * time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if d := b.delay(tt.attempt); d < tt.max/2 || d > tt.max {
+				t.Errorf("delay(%d) = %s, want between %s and %s", tt.attempt, d, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	networkErr := &GitError{Kind: ErrNetwork, Err: errors.New("exit status 128")}
+	notFoundErr := &GitError{Kind: ErrNotFound, Err: errors.New("exit status 128")}
+	tests := []struct {
+		name          string
+		policy        RetryPolicy
+		errs          []error // returned by successive attempts; nil after the last
+		expectCalls   int
+		expectRetries int
+		expectErr     error
+	}{
+		{"success", RetryPolicy{MaxAttempts: 3}, nil, 1, 0, nil},
+		{"transient", RetryPolicy{MaxAttempts: 3}, []error{networkErr, networkErr}, 3, 2, nil},
+		{"attempts run out", RetryPolicy{MaxAttempts: 2}, []error{networkErr, networkErr, networkErr}, 2, 1, ErrNetwork},
+		{"not worth retrying", RetryPolicy{MaxAttempts: 3}, []error{notFoundErr}, 1, 0, ErrNotFound},
+		{"no retries", RetryPolicy{MaxAttempts: 1}, []error{networkErr}, 1, 0, ErrNetwork},
+		{"deadline", RetryPolicy{MaxAttempts: 3, Deadline: time.Millisecond}, []error{networkErr}, 1, 0, ErrNetwork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			noSleep(t)
+			calls := 0
+			retries, err := tt.policy.retry(context.Background(), "test", func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if calls != tt.expectCalls || retries != tt.expectRetries {
+				t.Errorf("retry() made %d calls and %d retries, want %d and %d", calls, retries, tt.expectCalls, tt.expectRetries)
+			}
+			if tt.expectErr == nil && err != nil || !errors.Is(err, tt.expectErr) {
+				t.Errorf("retry() error = %v, want %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+// sequenceHTTPClient returns its responses in order, then the last one, or
+// err for every request if it is set
+type sequenceHTTPClient struct {
+	statuses []int
+	err      error
+	calls    int
+	ctx      context.Context // of the last request
+}
+
+func (c *sequenceHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	c.calls++
+	c.ctx = req.Context()
+	if c.err != nil {
+		return nil, c.err
+	}
+	status := c.statuses[min(c.calls-1, len(c.statuses)-1)]
+	body := `<meta name="go-import" content="example.com/pkg git https://git.example.com/pkg">`
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestRetryingClient(t *testing.T) {
+	noSleep(t)
+	inner := &sequenceHTTPClient{statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}}
+	client := &retryingClient{ctx: context.Background(), client: inner, policy: RetryPolicy{MaxAttempts: 3}}
+	_, _, repoURL, err := discoverGoImport("example.com/pkg", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repoURL != "https://git.example.com/pkg" || client.retries != 2 {
+		t.Errorf("discoverGoImport() = %q after %d retries, want https://git.example.com/pkg after 2", repoURL, client.retries)
+	}
+
+	inner = &sequenceHTTPClient{statuses: []int{http.StatusBadGateway}}
+	client = &retryingClient{ctx: context.Background(), client: inner, policy: RetryPolicy{MaxAttempts: 2}}
+	if _, _, _, err := discoverGoImport("example.com/pkg", client); err == nil || !strings.Contains(err.Error(), "got status 502") {
+		t.Errorf("discoverGoImport() error = %v, want got status 502", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("made %d requests, want 2", inner.calls)
+	}
+}
+
+func TestRetryingClientErrors(t *testing.T) {
+	noSleep(t)
+	tests := []struct {
+		name        string
+		err         error
+		expectErr   error
+		expectCalls int
+	}{
+		{"network", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, ErrNetwork, 3},
+		{"untrusted certificate", &url.Error{Op: "Get", URL: "https://example.com", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}}, ErrTLS, 1},
+		{"wrong host", x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}, ErrTLS, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			inner := &sequenceHTTPClient{err: tt.err}
+			client := &retryingClient{ctx: ctx, client: inner, policy: RetryPolicy{MaxAttempts: 3}}
+			_, err := client.Get("https://example.com/pkg?go-get=1")
+			if !errors.Is(err, tt.expectErr) {
+				t.Errorf("Get() error = %v, want %v", err, tt.expectErr)
+			}
+			if inner.calls != tt.expectCalls {
+				t.Errorf("made %d requests, want %d", inner.calls, tt.expectCalls)
+			}
+			if inner.ctx != ctx {
+				t.Error("request does not carry the client's context")
+			}
+		})
+	}
+}
+
+// flakyCloner fails the first failures clones with a network error, after
+// leaving a partial clone behind, then clones like fakeCloner
+type flakyCloner struct {
+	fakeCloner
+	failures int
+}
+
+func (f *flakyCloner) Clone(ctx context.Context, cmd *GitCommand, dir string, opts Options) error {
+	if f.failures > 0 {
+		f.failures--
+		os.WriteFile(filepath.Join(dir, "partial"), nil, 0644)
+		return &GitError{Kind: ErrNetwork, URL: cmd.URL, Err: errors.New("kex_exchange_identification: Connection reset by peer")}
+	}
+	return f.fakeCloner.Clone(ctx, cmd, dir, opts)
+}
+
+func TestRunGoGetRetries(t *testing.T) {
+	noSleep(t)
+	gopath := t.TempDir()
+	cloner := &flakyCloner{
+		fakeCloner: fakeCloner{repos: map[string]map[string]string{
+			"git@github.com:user/repo.git": {"go.mod": "module github.com/user/repo\n"},
+		}},
+		failures: 2,
+	}
+	opts := Options{Cloner: cloner, Retry: RetryPolicy{MaxAttempts: 3}}
+
+	result, err := runGoGet(context.Background(), "github.com/user/repo", gopath, gopath, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Retries != 2 {
+		t.Errorf("Retries = %d, want 2", result.Retries)
+	}
+	repoDir := filepath.Join(gopath, "src", "github.com", "user", "repo")
+	if _, err := os.Stat(filepath.Join(repoDir, "partial")); err == nil {
+		t.Error("files from a failed attempt were moved into place")
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(repoDir), ".repo.goget-tmp-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary directories left behind: %v", leftovers)
+	}
+}
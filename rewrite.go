@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// urlRewrite is one url.<base>.insteadOf or pushInsteadOf rule: URLs
+// starting with Prefix are rewritten to start with Base instead
+type urlRewrite struct {
+	Base   string
+	Prefix string
+	Push   bool // pushInsteadOf, which only applies to push URLs
+}
+
+// URLRules holds rewrite rules in the form of git's url.<base> sections
+type URLRules struct {
+	InsteadOf     []string `json:"insteadOf,omitempty"`
+	PushInsteadOf []string `json:"pushInsteadOf,omitempty"`
+}
+
+// gitURLRewrites returns the insteadOf and pushInsteadOf rules in the
+// user's global and system git configuration
+func gitURLRewrites(ctx context.Context) []urlRewrite {
+	out, err := gitOutput(ctx, "", "config", "--get-regexp", `^url\..*\.(insteadof|pushinsteadof)$`)
+	if err != nil {
+		// No rules, or no git
+		return nil
+	}
+	var rules []urlRewrite
+	for line := range strings.SplitSeq(out, "\n") {
+		// "url.git@github.com:.insteadof https://github.com/"
+		key, prefix, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		key = strings.TrimPrefix(key, "url.")
+		if base, ok := strings.CutSuffix(key, ".pushinsteadof"); ok {
+			rules = append(rules, urlRewrite{Base: base, Prefix: prefix, Push: true})
+		} else if base, ok := strings.CutSuffix(key, ".insteadof"); ok {
+			rules = append(rules, urlRewrite{Base: base, Prefix: prefix})
+		}
+	}
+	return rules
+}
+
+// urlRewrites returns the rewrite rules in goget's configuration file
+func (c *UserConfig) urlRewrites() []urlRewrite {
+	if c == nil {
+		return nil
+	}
+	var rules []urlRewrite
+	for base, r := range c.URLs {
+		for _, prefix := range r.InsteadOf {
+			rules = append(rules, urlRewrite{Base: base, Prefix: prefix})
+		}
+		for _, prefix := range r.PushInsteadOf {
+			rules = append(rules, urlRewrite{Base: base, Prefix: prefix, Push: true})
+		}
+	}
+	return rules
+}
+
+// rewriteURL applies the rule with the longest prefix matching url, as git
+// does. Push URLs use pushInsteadOf rules, and insteadOf rules if none match.
+func rewriteURL(rules []urlRewrite, url string, push bool) string {
+	best := -1
+	for _, kind := range []bool{true, false} {
+		if kind && !push {
+			continue
+		}
+		for i, rule := range rules {
+			if rule.Push == kind && strings.HasPrefix(url, rule.Prefix) && (best == -1 || len(rule.Prefix) > len(rules[best].Prefix)) {
+				best = i
+			}
+		}
+		if best != -1 {
+			return rules[best].Base + strings.TrimPrefix(url, rules[best].Prefix)
+		}
+	}
+	return url
+}
+
+// rewrittenURLs returns the URL goget passes to git for repoURL, rewritten by
+// goget's rules, which git does not know, and the URL git really fetches
+// from, rewritten by git's own insteadOf rules as well
+func rewrittenURLs(repoURL string, opts Options) (gitURL, url string) {
+	gitURL = rewriteURL(opts.UserConfig.urlRewrites(), repoURL, false)
+	return gitURL, rewriteURL(opts.GitURLRewrites, gitURL, false)
+}
+
+// effectiveURL returns the URL git really fetches repoURL from
+func effectiveURL(repoURL string, opts Options) string {
+	_, url := rewrittenURLs(repoURL, opts)
+	return url
+}
+
+// effectivePushURL is like effectiveURL, for the URL git pushes repoURL to.
+// git matches its pushInsteadOf rules against the URL it was given, so they
+// apply before its insteadOf rules, not after.
+func effectivePushURL(repoURL string, opts Options) string {
+	if push := gogetPushURL(repoURL, opts); push != "" {
+		return push
+	}
+	gitURL, _ := rewrittenURLs(repoURL, opts)
+	return rewriteURL(opts.GitURLRewrites, gitURL, true)
+}
+
+// gogetPushURL returns the URL that pushes to repoURL go to under the
+// pushInsteadOf rules in goget's configuration, or "" if they don't change
+// it. git never sees those rules, so the URL has to be set as the remote's
+// pushurl, which git rewrites with its insteadOf rules only.
+func gogetPushURL(repoURL string, opts Options) string {
+	rules := opts.UserConfig.urlRewrites()
+	push := rewriteURL(rules, repoURL, true)
+	if push == rewriteURL(rules, repoURL, false) {
+		return ""
+	}
+	return rewriteURL(opts.GitURLRewrites, push, false)
+}
+
+// setURL makes c clone from url instead of c.URL
+func (c *GitCommand) setURL(url string) {
+	if i := slices.Index(c.Args, c.gitURL()); i != -1 {
+		c.Args[i] = url
+	}
+	c.URL, c.GitURL = url, ""
+}
+
+// setRewrittenURL makes c clone from repoURL as the rules rewrite it. git is
+// passed the URL its own rules apply to, so the clone's remote keeps it and
+// its pushInsteadOf rules still match, while c.URL is the URL git really
+// fetches from.
+func (c *GitCommand) setRewrittenURL(repoURL string, opts Options) {
+	gitURL, url := rewrittenURLs(repoURL, opts)
+	c.setURL(gitURL)
+	if url != gitURL {
+		c.URL, c.GitURL = url, gitURL
+	}
+}
+
+// applyRewrites points c at the effective URL of the repository it clones,
+// saying so if a rule changed it
+func (c *GitCommand) applyRewrites(opts Options) {
+	if url := effectiveURL(c.URL, opts); url != c.URL {
+		fmt.Printf("Rewrote %s to %s\n", c.URL, url)
+		c.setRewrittenURL(c.URL, opts)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRewriteURL(t *testing.T) {
+	rules := []urlRewrite{
+		{Base: "git@github.com:", Prefix: "https://github.com/"},
+		{Base: "https://git.internal/mirror/ourorg/", Prefix: "https://github.com/ourorg/"},
+		{Base: "git@git.internal:", Prefix: "https://git.internal/", Push: true},
+	}
+	tests := []struct {
+		url      string
+		push     bool
+		expected string
+	}{
+		{"https://github.com/user/repo.git", false, "git@github.com:user/repo.git"},
+		{"https://github.com/ourorg/repo.git", false, "https://git.internal/mirror/ourorg/repo.git"},
+		{"https://gitlab.com/user/repo.git", false, "https://gitlab.com/user/repo.git"},
+		{"https://git.internal/team/repo.git", false, "https://git.internal/team/repo.git"},
+		{"https://git.internal/team/repo.git", true, "git@git.internal:team/repo.git"},
+		{"https://github.com/user/repo.git", true, "git@github.com:user/repo.git"},
+	}
+	for _, tt := range tests {
+		if got := rewriteURL(rules, tt.url, tt.push); got != tt.expected {
+			t.Errorf("rewriteURL(%q, push=%v) = %q, want %q", tt.url, tt.push, got, tt.expected)
+		}
+	}
+}
+
+func TestGitURLRewrites(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	global := isolateGitConfig(t)
+	for _, kv := range [][2]string{
+		{"url.git@github.com:.insteadOf", "https://github.com/"},
+		{"url.ssh://git@git.internal/.pushInsteadOf", "https://git.internal/"},
+	} {
+		if out, err := exec.Command("git", "config", "--file", global, "--add", kv[0], kv[1]).CombinedOutput(); err != nil {
+			t.Fatalf("git config: %v\n%s", err, out)
+		}
+	}
+	expected := []urlRewrite{
+		{Base: "git@github.com:", Prefix: "https://github.com/"},
+		{Base: "ssh://git@git.internal/", Prefix: "https://git.internal/", Push: true},
+	}
+	if got := gitURLRewrites(context.Background()); !slices.Equal(got, expected) {
+		t.Errorf("gitURLRewrites() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestRunGoGetRewrites(t *testing.T) {
+	tests := []struct {
+		name         string
+		arg          string
+		opts         Options
+		repos        map[string]map[string]string
+		expectClones []string
+	}{
+		{
+			name: "goget rule routes an organization through a mirror",
+			arg:  "github.com/ourorg/repo",
+			opts: Options{HTTPS: true, UserConfig: &UserConfig{URLs: map[string]URLRules{
+				"https://git.internal/mirror/ourorg/": {InsteadOf: []string{"https://github.com/ourorg/"}},
+			}}},
+			repos: map[string]map[string]string{
+				"https://git.internal/mirror/ourorg/repo.git": {"go.mod": "module github.com/ourorg/repo\n"},
+			},
+			expectClones: []string{"https://git.internal/mirror/ourorg/repo.git"},
+		},
+		{
+			name: "git rule makes the HTTPS fallback pointless",
+			arg:  "github.com/user/repo",
+			opts: Options{GitURLRewrites: []urlRewrite{{Base: "git@github.com:", Prefix: "https://github.com/"}}},
+			repos: map[string]map[string]string{
+				"https://github.com/user/repo.git": {"go.mod": "module github.com/user/repo\n"},
+			},
+			expectClones: []string{"git@github.com:user/repo.git"},
+		},
+		{
+			name: "SSH rewritten to HTTPS is not treated as SSH",
+			arg:  "github.com/user/repo",
+			opts: Options{GitURLRewrites: []urlRewrite{{Base: "https://github.com/", Prefix: "git@github.com:"}}},
+			repos: map[string]map[string]string{
+				"https://github.com/user/repo.git": {"go.mod": "module github.com/user/repo\n"},
+			},
+			expectClones: []string{"https://github.com/user/repo.git"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gopath := t.TempDir()
+			fake := &fakeCloner{repos: tt.repos}
+			opts := tt.opts
+			opts.Cloner = fake
+			runGoGet(context.Background(), tt.arg, gopath, gopath, opts)
+			if !slices.Equal(fake.clones, tt.expectClones) {
+				t.Errorf("clones = %v, want %v", fake.clones, tt.expectClones)
+			}
+		})
+	}
+}
+
+func TestEffectivePushURL(t *testing.T) {
+	const fork = "https://github.com/me/repo.git"
+	tests := []struct {
+		name        string
+		opts        Options
+		expectPush  string
+		expectGoget string
+	}{
+		{
+			name: "no rules",
+		},
+		{
+			name: "goget rule becomes the push URL",
+			opts: Options{UserConfig: &UserConfig{URLs: map[string]URLRules{
+				"git@github.com:": {PushInsteadOf: []string{"https://github.com/"}},
+			}}},
+			expectPush:  "git@github.com:me/repo.git",
+			expectGoget: "git@github.com:me/repo.git",
+		},
+		{
+			name: "git rewrites the push URL of a goget rule with insteadOf",
+			opts: Options{
+				UserConfig: &UserConfig{URLs: map[string]URLRules{
+					"git@github.com:": {PushInsteadOf: []string{"https://github.com/"}},
+				}},
+				GitURLRewrites: []urlRewrite{{Base: "ssh://git@ssh.github.com:443/", Prefix: "git@github.com:"}},
+			},
+			expectPush:  "ssh://git@ssh.github.com:443/me/repo.git",
+			expectGoget: "ssh://git@ssh.github.com:443/me/repo.git",
+		},
+		{
+			name:       "git rule is left to git",
+			opts:       Options{GitURLRewrites: []urlRewrite{{Base: "git@github.com:", Prefix: "https://github.com/", Push: true}}},
+			expectPush: "git@github.com:me/repo.git",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectPush := cmp.Or(tt.expectPush, fork)
+			if got := effectivePushURL(fork, tt.opts); got != expectPush {
+				t.Errorf("effectivePushURL() = %q, want %q", got, expectPush)
+			}
+			if got := gogetPushURL(fork, tt.opts); got != tt.expectGoget {
+				t.Errorf("gogetPushURL() = %q, want %q", got, tt.expectGoget)
+			}
+		})
+	}
+}
+
+func TestRunGoGetKeepsURLForGitRules(t *testing.T) {
+	ctx := context.Background()
+	global := isolateGitConfig(t)
+	root := t.TempDir()
+	initTestRepo(t, filepath.Join(root, "user", "repo.git"))
+	for _, kv := range [][2]string{
+		{"url.file://" + root + "/.insteadOf", "https://github.com/"},
+		{"url.ssh://git@push.example.com/.pushInsteadOf", "https://github.com/"},
+	} {
+		if out, err := exec.Command("git", "config", "--file", global, "--add", kv[0], kv[1]).CombinedOutput(); err != nil {
+			t.Fatalf("git config: %v\n%s", err, out)
+		}
+	}
+
+	gopath := t.TempDir()
+	opts := Options{HTTPS: true, GitURLRewrites: gitURLRewrites(ctx)}
+	if _, err := runGoGet(ctx, "github.com/user/repo", gopath, gopath, opts); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(gopath, "src", "github.com", "user", "repo")
+	// git applies its rules to the URL in the clone's configuration, so
+	// pushes go where its pushInsteadOf rule sends them
+	for _, tt := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"config", "--get", "remote.origin.url"}, "https://github.com/user/repo.git"},
+		{[]string{"remote", "get-url", "--push", "origin"}, "ssh://git@push.example.com/user/repo.git"},
+	} {
+		if got, err := gitOutput(ctx, dir, tt.args...); err != nil || got != tt.expected {
+			t.Errorf("git %v = %q, %v; want %q", tt.args, got, err, tt.expected)
+		}
+	}
+}
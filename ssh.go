@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// userSSHCommand returns the ssh command the user configured git to run for
+// the repository in dir (or globally, if dir is ""): GIT_SSH_COMMAND, then
+// GIT_SSH, then core.sshCommand, in git's order of precedence. It returns ""
+// if there is none.
+func userSSHCommand(ctx context.Context, dir string) string {
+	if command := os.Getenv("GIT_SSH_COMMAND"); command != "" {
+		return command
+	}
+	if program := os.Getenv("GIT_SSH"); program != "" {
+		// GIT_SSH names a program, run without a shell
+		return shellQuote(program)
+	}
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			dir = ""
+		}
+	}
+	command, _ := gitOutput(ctx, dir, "config", "--get", "core.sshCommand")
+	return command
+}
+
+// sshCommand returns the command git should run for ssh when talking to
+// repoURL from the repository in dir: the user's own ssh command, or plain
+// ssh, with options that make it fail fast instead of hanging on prompts,
+// check pinned host keys, and use the identity file goget's configuration
+// names for repoURL. --accept-ssh-host never applies to hosts with pinned
+// keys.
+//
+// The options are appended, so they reach a wrapper script as arguments, and
+// ssh options the user already passed with -o take precedence: ssh uses the
+// first value it sees for each option.
+func sshCommand(ctx context.Context, dir, repoURL string, opts Options) string {
+	command := userSSHCommand(ctx, dir)
+	if command == "" {
+		command = "ssh"
+	}
+	if isPlink(command) {
+		// PuTTY's plink takes none of ssh's options
+		return command
+	}
+	command += " -o BatchMode=yes"
+	pinnedOpts, pinned := pinnedSSHOptions(ctx, repoURL, opts)
+	command += pinnedOpts
+	if opts.AcceptSSHHost && !pinned {
+		// Accept new host keys automatically (but still reject changed keys)
+		command += " -o StrictHostKeyChecking=accept-new"
+	}
+	if identity := opts.UserConfig.host(repoURL).IdentityFile; identity != "" {
+		command += " -i " + shellQuote(identity) + " -o IdentitiesOnly=yes"
+	}
+	return command
+}
+
+// isPlink reports whether the ssh command runs PuTTY's plink, which git
+// drives with different arguments than OpenSSH
+func isPlink(command string) bool {
+	command = strings.TrimSpace(command)
+	program, _, _ := strings.Cut(command, " ")
+	if quote := command[:min(1, len(command))]; quote == `"` || quote == "'" {
+		program, _, _ = strings.Cut(command[1:], quote)
+	}
+	// Windows paths use backslashes, which filepath.Base only splits on
+	// Windows
+	program = filepath.Base(strings.ReplaceAll(program, `\`, "/"))
+	program = strings.TrimSuffix(strings.ToLower(program), ".exe")
+	return program == "plink" || program == "tortoiseplink"
+}
+
+// shellQuote quotes s for the shell git runs GIT_SSH_COMMAND with
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./~=:@+,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// sshPort returns the port of an ssh:// URL, or "" for the default port and
+// for other URLs
+func sshPort(repoURL string) string {
+	rest, ok := strings.CutPrefix(repoURL, "ssh://")
+	if !ok {
+		rest, ok = strings.CutPrefix(repoURL, "git+ssh://")
+	}
+	if !ok {
+		return ""
+	}
+	hostPort, _, _ := strings.Cut(rest, "/")
+	if at := strings.LastIndex(hostPort, "@"); at != -1 {
+		hostPort = hostPort[at+1:]
+	}
+	if _, port, ok := strings.Cut(hostPort, ":"); ok && port != "22" {
+		return port
+	}
+	return ""
+}
+
+// repoRemoteURL returns the URL of the remote of the repository in dir that
+// goget talks to: origin, or the only remote there is, or "" if neither
+// exists. Its ssh settings apply to commands that don't name a remote.
+func repoRemoteURL(ctx context.Context, dir string) string {
+	out, err := gitOutput(ctx, dir, "remote")
+	if err != nil || out == "" {
+		return ""
+	}
+	remotes := strings.Fields(out)
+	remote := remotes[0]
+	if slices.Contains(remotes, "origin") {
+		remote = "origin"
+	} else if len(remotes) > 1 {
+		return ""
+	}
+	url, _ := gitOutput(ctx, dir, "remote", "get-url", remote)
+	return url
+}
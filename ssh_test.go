@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolateGitConfig points git at empty global and system config files, so
+// the tests don't see the core.sshCommand of the machine they run on
+func isolateGitConfig(t *testing.T) string {
+	t.Helper()
+	global := filepath.Join(t.TempDir(), "gitconfig")
+	os.WriteFile(global, nil, 0644)
+	t.Setenv("GIT_CONFIG_GLOBAL", global)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_SSH_COMMAND", "")
+	t.Setenv("GIT_SSH", "")
+	return global
+}
+
+func TestSSHCommand(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	config := &UserConfig{Hosts: map[string]HostConfig{
+		"github.com/acme": {IdentityFile: "/keys/acme deploy"},
+	}}
+	tests := []struct {
+		name       string
+		env        map[string]string
+		sshCommand string // core.sshCommand
+		url        string
+		opts       Options
+		expected   string
+	}{
+		{
+			name:     "default",
+			url:      "git@github.com:user/repo.git",
+			expected: "ssh -o BatchMode=yes",
+		},
+		{
+			name:     "accept new host keys",
+			url:      "git@github.com:user/repo.git",
+			opts:     Options{AcceptSSHHost: true},
+			expected: "ssh -o BatchMode=yes -o StrictHostKeyChecking=accept-new",
+		},
+		{
+			name:       "GIT_SSH_COMMAND",
+			env:        map[string]string{"GIT_SSH_COMMAND": "ssh -J bastion.example.com"},
+			sshCommand: "ssh -i /keys/ignored",
+			url:        "git@github.com:user/repo.git",
+			expected:   "ssh -J bastion.example.com -o BatchMode=yes",
+		},
+		{
+			name:     "GIT_SSH",
+			env:      map[string]string{"GIT_SSH": "/opt/my ssh/wrapper"},
+			url:      "git@github.com:user/repo.git",
+			expected: "'/opt/my ssh/wrapper' -o BatchMode=yes",
+		},
+		{
+			name:       "core.sshCommand",
+			sshCommand: "ssh -F /etc/goget/ssh_config",
+			url:        "git@github.com:user/repo.git",
+			expected:   "ssh -F /etc/goget/ssh_config -o BatchMode=yes",
+		},
+		{
+			name:     "identity from config",
+			url:      "git@github.com:acme/repo.git",
+			opts:     Options{UserConfig: config},
+			expected: "ssh -o BatchMode=yes -i '/keys/acme deploy' -o IdentitiesOnly=yes",
+		},
+		{
+			name:     "plink",
+			env:      map[string]string{"GIT_SSH_COMMAND": `"C:\Program Files\PuTTY\plink.exe" -batch`},
+			url:      "git@github.com:acme/repo.git",
+			opts:     Options{UserConfig: config, AcceptSSHHost: true},
+			expected: `"C:\Program Files\PuTTY\plink.exe" -batch`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			global := isolateGitConfig(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if tt.sshCommand != "" {
+				if out, err := exec.Command("git", "config", "--file", global, "core.sshCommand", tt.sshCommand).CombinedOutput(); err != nil {
+					t.Fatalf("git config: %v\n%s", err, out)
+				}
+			}
+			if got := sshCommand(context.Background(), "", tt.url, tt.opts); got != tt.expected {
+				t.Errorf("sshCommand() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"/home/user/.ssh/id_ed25519", "/home/user/.ssh/id_ed25519"},
+		{"/keys/acme deploy", "'/keys/acme deploy'"},
+		{"it's", `'it'\''s'`},
+		{"", "''"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.expected {
+			t.Errorf("shellQuote(%q) = %q, want %q", tt.in, got, tt.expected)
+		}
+	}
+}
+
+func TestSSHPort(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"ssh://git@git.example.com:2222/team/repo.git", "2222"},
+		{"git+ssh://git.example.com:2222/team/repo.git", "2222"},
+		{"ssh://git@git.example.com:22/team/repo.git", ""},
+		{"ssh://git@git.example.com/team/repo.git", ""},
+		{"git@github.com:user/repo.git", ""},
+		{"https://git.example.com:8443/team/repo.git", ""},
+	}
+	for _, tt := range tests {
+		if got := sshPort(tt.url); got != tt.expected {
+			t.Errorf("sshPort(%q) = %q, want %q", tt.url, got, tt.expected)
+		}
+	}
+}
+
+// TestCloneRunsUserSSHCommand clones through an ssh wrapper set in
+// GIT_SSH_COMMAND, and checks that goget's options reach it along with the
+// port of an ssh:// URL and the identity from goget's config
+func TestCloneRunsUserSSHCommand(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	isolateGitConfig(t)
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "ssh.log")
+	// Named ssh so git passes it OpenSSH's arguments, such as -p
+	wrapper := filepath.Join(dir, "ssh")
+	script := "#!/bin/sh\necho \"$@\" >> " + logFile + "\nexit 255\n"
+	if err := os.WriteFile(wrapper, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_SSH_COMMAND", wrapper+" --from-user")
+
+	url := "ssh://git@git.example.com:2222/team/repo.git"
+	opts := Options{UserConfig: &UserConfig{Hosts: map[string]HostConfig{
+		"git.example.com:2222": {IdentityFile: "/keys/team"},
+	}}}
+	cmd := &GitCommand{URL: url, Args: []string{"clone", "--quiet", url, filepath.Join(dir, "repo")}}
+	if err := (execCloner{}).Clone(context.Background(), cmd, filepath.Join(dir, "tmp"), opts); err == nil {
+		t.Fatal("Clone() succeeded through a failing ssh")
+	}
+
+	args, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("ssh wrapper was not run: %v", err)
+	}
+	for _, want := range []string{"--from-user -o BatchMode=yes -i /keys/team -o IdentitiesOnly=yes", "-p 2222", "git@git.example.com"} {
+		if !strings.Contains(string(args), want) {
+			t.Errorf("ssh arguments %q do not contain %q", args, want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// RepoStatus describes the local state of one repository under $GOPATH/src
+type RepoStatus struct {
+	Path      string   `json:"path"`
+	Branch    string   `json:"branch"`
+	Upstream  string   `json:"upstream,omitempty"`
+	Ahead     int      `json:"ahead"`
+	Behind    int      `json:"behind"`
+	Dirty     int      `json:"dirty"`
+	Untracked int      `json:"untracked"`
+	Stashes   int      `json:"stashes"`
+	Unpushed  []string `json:"unpushed,omitempty"` // local branches with commits not on a remote
+	Error     string   `json:"error,omitempty"`
+}
+
+// HasLocalWork reports whether the repository holds anything that would be
+// lost if it were deleted
+func (s *RepoStatus) HasLocalWork() bool {
+	return s.Dirty > 0 || s.Untracked > 0 || s.Ahead > 0 || s.Stashes > 0 || len(s.Unpushed) > 0
+}
+
+// findRepositories walks srcDir and returns the directories holding
+// checkouts, detected the same way executeGitCommand detects an existing
+// clone. Directories inside a repository are not searched.
+func findRepositories(srcDir string) ([]string, error) {
+	var repos []string
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == srcDir {
+				return err
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != srcDir && strings.HasPrefix(d.Name(), ".") {
+			// Hidden directories, including goget's temporary clones
+			return filepath.SkipDir
+		}
+		if existingCheckout(path) != "" {
+			repos = append(repos, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return repos, err
+}
+
+// parseStatusV2 fills in branch and work tree information from the output of
+// "git status --porcelain=v2 --branch"
+func parseStatusV2(out string, status *RepoStatus) {
+	for line := range strings.SplitSeq(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# branch.head "):
+			status.Branch = strings.TrimPrefix(line, "# branch.head ")
+		case strings.HasPrefix(line, "# branch.upstream "):
+			status.Upstream = strings.TrimPrefix(line, "# branch.upstream ")
+		case strings.HasPrefix(line, "# branch.ab "):
+			var ahead, behind int
+			if _, err := fmt.Sscanf(strings.TrimPrefix(line, "# branch.ab "), "+%d -%d", &ahead, &behind); err == nil {
+				status.Ahead, status.Behind = ahead, behind
+			}
+		case strings.HasPrefix(line, "1 "), strings.HasPrefix(line, "2 "), strings.HasPrefix(line, "u "):
+			status.Dirty++
+		case strings.HasPrefix(line, "? "):
+			status.Untracked++
+		}
+	}
+}
+
+// parseUnpushedBranches returns the branches in the output of
+// "git for-each-ref --format=%(refname:short)%09%(upstream:short)%09%(upstream:track) refs/heads"
+// that have no upstream, or are ahead of it
+func parseUnpushedBranches(out string) []string {
+	var branches []string
+	for line := range strings.SplitSeq(out, "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		for len(fields) < 3 {
+			fields = append(fields, "")
+		}
+		name, upstream, track := fields[0], fields[1], fields[2]
+		if upstream == "" || strings.Contains(track, "ahead") {
+			branches = append(branches, name)
+		}
+	}
+	return branches
+}
+
+// repoStatus collects the status of the repository in dir
+func repoStatus(ctx context.Context, dir string) RepoStatus {
+	status := RepoStatus{Path: dir}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		status.Error = "not a git repository (go.mod only)"
+		return status
+	}
+
+	out, err := gitOutput(ctx, dir, "status", "--porcelain=v2", "--branch")
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	parseStatusV2(out, &status)
+
+	if stashes, err := gitOutput(ctx, dir, "stash", "list"); err == nil && stashes != "" {
+		status.Stashes = strings.Count(stashes, "\n") + 1
+	}
+
+	refs, err := gitOutput(ctx, dir, "for-each-ref", "--format=%(refname:short)%09%(upstream:short)%09%(upstream:track)", "refs/heads")
+	if err == nil {
+		status.Unpushed = parseUnpushedBranches(refs)
+	}
+	return status
+}
+
+// collectStatus reports the status of every repository under srcDir, running
+// up to maxParallel git commands at once like runGoGetParallel
+func collectStatus(ctx context.Context, srcDir string) ([]RepoStatus, error) {
+	repos, err := findRepositories(srcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]RepoStatus, len(repos))
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxParallel)
+	for i, repo := range repos {
+		g.Go(func() error {
+			status := repoStatus(ctx, repo)
+			if rel, err := filepath.Rel(srcDir, repo); err == nil {
+				status.Path = filepath.ToSlash(rel)
+			}
+			statuses[i] = status
+			return nil
+		})
+	}
+	_ = g.Wait() // errors are recorded per repository
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Path < statuses[j].Path })
+	return statuses, nil
+}
+
+// printStatusTable writes statuses as an aligned table
+func printStatusTable(w io.Writer, statuses []RepoStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tBRANCH\tDIRTY\tUNTRACKED\tAHEAD\tBEHIND\tSTASHES\tUNPUSHED")
+	for _, s := range statuses {
+		if s.Error != "" {
+			fmt.Fprintf(tw, "%s\tERROR: %s\t\t\t\t\t\t\n", s.Path, s.Error)
+			continue
+		}
+		branch := s.Branch
+		if s.Upstream == "" && branch != "(detached)" {
+			branch += " (no upstream)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\n", s.Path, branch, s.Dirty, s.Untracked, s.Ahead, s.Behind, s.Stashes, strings.Join(s.Unpushed, ","))
+	}
+	return tw.Flush()
+}
+
+// runStatus implements "goget status [--json] [--local-work]"
+func runStatus(ctx context.Context, args []string, gopath string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	jsonFlag := flags.Bool("json", false, "print the report as JSON")
+	localWorkFlag := flags.Bool("local-work", false, "only show repositories with uncommitted, unpushed or stashed work")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if gopath == "" {
+		return fmt.Errorf("cannot report status without GOPATH set")
+	}
+	srcDir := filepath.Join(firstGOPATH(gopath), "src")
+	statuses, err := collectStatus(ctx, srcDir)
+	if err != nil {
+		return err
+	}
+
+	if *localWorkFlag {
+		filtered := statuses[:0]
+		for _, s := range statuses {
+			if s.HasLocalWork() || s.Error != "" {
+				filtered = append(filtered, s)
+			}
+		}
+		statuses = filtered
+	}
+
+	if *jsonFlag {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(statuses)
+	}
+	if err := printStatusTable(os.Stdout, statuses); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d repositories in %s\n", len(statuses), srcDir)
+	return nil
+}
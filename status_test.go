@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseStatusV2(t *testing.T) {
+	out := `# branch.oid 1234567890abcdef1234567890abcdef12345678
+# branch.head main
+# branch.upstream origin/main
+# branch.ab +2 -3
+1 .M N... 100644 100644 100644 abc abc main.go
+2 R. N... 100644 100644 100644 abc abc R100 new.go	old.go
+u UU N... 100644 100644 100644 100644 abc abc abc conflict.go
+? untracked.go
+? other.txt`
+
+	var status RepoStatus
+	parseStatusV2(out, &status)
+
+	expected := RepoStatus{
+		Branch:    "main",
+		Upstream:  "origin/main",
+		Ahead:     2,
+		Behind:    3,
+		Dirty:     3,
+		Untracked: 2,
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("parseStatusV2() = %+v, want %+v", status, expected)
+	}
+}
+
+func TestParseUnpushedBranches(t *testing.T) {
+	out := "main\torigin/main\t\n" +
+		"feature\torigin/feature\t[ahead 2]\n" +
+		"behind\torigin/behind\t[behind 1]\n" +
+		"local\t\t\n"
+
+	got := parseUnpushedBranches(out)
+	expected := []string{"feature", "local"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseUnpushedBranches() = %v, want %v", got, expected)
+	}
+}
+
+func TestCollectStatus(t *testing.T) {
+	gopath := t.TempDir()
+	srcDir := filepath.Join(gopath, "src")
+
+	upstream := filepath.Join(t.TempDir(), "upstream")
+	initTestRepo(t, upstream)
+
+	clean := filepath.Join(srcDir, "github.com", "user", "clean")
+	dirty := filepath.Join(srcDir, "github.com", "user", "dirty")
+	for _, dir := range []string{clean, dirty} {
+		if _, err := gitOutput(context.Background(), "", "clone", "--quiet", upstream, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dirty, "doc.go"), []byte("package changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, srcDir, map[string]string{
+		"example.com/copied/go.mod": "module example.com/copied\n",
+		// A clone left behind by a goget run that was killed
+		"github.com/user/.clean.goget-tmp-2147483646-1/go.mod": "module github.com/user/clean\n",
+	})
+
+	statuses, err := collectStatus(context.Background(), srcDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(statuses) != 3 {
+		t.Fatalf("expected 3 repositories, got %+v", statuses)
+	}
+
+	if statuses[0].Path != "example.com/copied" || statuses[0].Error == "" {
+		t.Errorf("expected an error for a directory without .git, got %+v", statuses[0])
+	}
+	if statuses[1].Path != "github.com/user/clean" || statuses[1].HasLocalWork() || statuses[1].Branch != "main" {
+		t.Errorf("unexpected status for clean repo: %+v", statuses[1])
+	}
+	if statuses[2].Path != "github.com/user/dirty" || statuses[2].Dirty != 1 {
+		t.Errorf("unexpected status for dirty repo: %+v", statuses[2])
+	}
+}
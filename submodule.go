@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Modes for --lfs
+const (
+	lfsSkip = "skip"
+	lfsPull = "pull"
+)
+
+// submoduleFailure matches git's report of a submodule it could not clone
+var submoduleFailure = regexp.MustCompile(`clone of '([^']+)' into submodule path '([^']+)' failed`)
+
+// submoduleURLs returns the URLs in the .gitmodules file of the checkout in
+// dir
+func submoduleURLs(ctx context.Context, dir string) []string {
+	out, err := gitOutput(ctx, dir, "config", "--file", ".gitmodules", "--get-regexp", `^submodule\..*\.url$`)
+	if err != nil {
+		// No .gitmodules, or no submodules in it
+		return nil
+	}
+	var urls []string
+	for line := range strings.SplitSeq(out, "\n") {
+		if _, url, ok := strings.Cut(line, " "); ok {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// protocolRewrites returns "git -c" arguments that rewrite URLs on the hosts
+// of urls to SSH, or to HTTPS if toSSH is false. A host with an ssh:// URL on
+// a non-default port among urls is reached over SSH on that port. Relative
+// submodule URLs need no rewriting: they resolve against the parent's
+// origin.
+func protocolRewrites(urls []string, toSSH bool) []string {
+	var hosts []string
+	ports := make(map[string]string)
+	for _, url := range urls {
+		if !strings.Contains(url, "://") && !isSSHURL(url) {
+			continue
+		}
+		if strings.HasPrefix(url, "file://") {
+			continue
+		}
+		if host := extractHostFromGitURL(url); host != "" && !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+		if port := sshPort(url); port != "" {
+			ports[extractHostFromGitURL(url)] = port
+		}
+	}
+	slices.Sort(hosts)
+
+	var args []string
+	for _, host := range hosts {
+		port := ports[host]
+		switch {
+		case toSSH && port != "":
+			args = append(args, "-c", fmt.Sprintf("url.ssh://git@%s:%s/.insteadOf=https://%s/", host, port, host))
+		case toSSH:
+			args = append(args, "-c", fmt.Sprintf("url.git@%s:.insteadOf=https://%s/", host, host))
+		default:
+			args = append(args,
+				"-c", fmt.Sprintf("url.https://%s/.insteadOf=git@%s:", host, host),
+				"-c", fmt.Sprintf("url.https://%s/.insteadOf=ssh://git@%s/", host, host))
+			if port != "" {
+				args = append(args, "-c", fmt.Sprintf("url.https://%s/.insteadOf=ssh://git@%s:%s/", host, host, port))
+			}
+		}
+	}
+	return args
+}
+
+// updateSubmodules checks out the submodules of the fresh clone in dir,
+// recursively, over the same protocol the parent was cloned with from
+// parentURL, falling back to HTTPS like the parent does
+func updateSubmodules(ctx context.Context, dir, parentURL string, opts Options) error {
+	urls := submoduleURLs(ctx, dir)
+	if len(urls) == 0 {
+		return nil
+	}
+
+	// Submodules are fetched with the ssh settings of the parent
+	env := remoteEnv(ctx, nil, dir, parentURL, opts, urls...)
+	if opts.LFS != "" {
+		env = append(env, "GIT_LFS_SKIP_SMUDGE=1")
+	}
+	update := func(toSSH bool) error {
+		// The parent's URL tells the SSH port of its host
+		args := append(protocolRewrites(append(urls, parentURL), toSSH), "submodule", "update", "--init", "--recursive", "--quiet")
+		_, err := gitOutputEnv(ctx, dir, env, args...)
+		return err
+	}
+
+	fmt.Printf("Checking out submodules of %s\n", dir)
+	useSSH := isSSHURL(parentURL)
+	err := update(useSSH)
+	if err != nil && useSSH && !opts.HTTPS {
+		log.Printf("SSH submodule update failed, falling back to HTTPS...")
+		err = update(false)
+	}
+	if err != nil {
+		msg := err.Error()
+		var gitErr *GitError
+		if errors.As(err, &gitErr) {
+			msg = gitErr.Output
+		}
+		if m := submoduleFailure.FindStringSubmatch(msg); m != nil {
+			path := m[2]
+			if rel, err := filepath.Rel(dir, path); err == nil && filepath.IsAbs(path) {
+				path = filepath.ToSlash(rel)
+			}
+			return fmt.Errorf("submodule %s (%s): %w", path, m[1], err)
+		}
+		return fmt.Errorf("could not check out submodules of %s: %w", dir, err)
+	}
+	return nil
+}
+
+// usesLFS reports whether any .gitattributes file in the checkout in dir
+// routes files through Git LFS
+func usesLFS(ctx context.Context, dir string) bool {
+	_, err := gitOutput(ctx, dir, "grep", "--quiet", "filter=lfs", "--", ":(glob)**/.gitattributes")
+	return err == nil
+}
+
+// handleLFS finishes a clone made with the LFS smudge filter disabled: with
+// lfsPull it downloads the LFS files (in submodules too, if they were checked
+// out), with lfsSkip it notes that pointer files were left in place
+func handleLFS(ctx context.Context, dir string, opts Options) error {
+	if opts.LFS == "" {
+		return nil
+	}
+	if !gitInstalled() {
+		log.Printf("WARN: not handling Git LFS files in %s: git is not installed", dir)
+		return nil
+	}
+	if !usesLFS(ctx, dir) {
+		return nil
+	}
+	if opts.LFS == lfsSkip {
+		fmt.Printf("Left Git LFS files in %s as pointers (--lfs=skip)\n", dir)
+		return nil
+	}
+
+	if _, err := exec.LookPath("git-lfs"); err != nil {
+		return fmt.Errorf("%s uses Git LFS, but git-lfs is not installed; LFS files are left as pointers", dir)
+	}
+	env := remoteEnv(ctx, nil, dir, repoRemoteURL(ctx, dir), opts)
+	commands := [][]string{{"lfs", "pull"}}
+	if opts.Submodules {
+		commands = append(commands, []string{"submodule", "foreach", "--quiet", "--recursive", "git lfs pull"})
+	}
+	for _, args := range commands {
+		if _, err := gitOutputEnv(ctx, dir, env, args...); err != nil {
+			return fmt.Errorf("could not download Git LFS files in %s: %w", dir, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProtocolRewrites(t *testing.T) {
+	urls := []string{
+		"https://github.com/user/a.git",
+		"git@gitlab.com:user/b.git",
+		"../c.git",
+		"file:///srv/git/d.git",
+		"https://github.com/user/e",
+	}
+
+	tests := []struct {
+		name     string
+		toSSH    bool
+		expected []string
+	}{
+		{
+			name:  "to SSH",
+			toSSH: true,
+			expected: []string{
+				"-c", "url.git@github.com:.insteadOf=https://github.com/",
+				"-c", "url.git@gitlab.com:.insteadOf=https://gitlab.com/",
+			},
+		},
+		{
+			name:  "to HTTPS",
+			toSSH: false,
+			expected: []string{
+				"-c", "url.https://github.com/.insteadOf=git@github.com:",
+				"-c", "url.https://github.com/.insteadOf=ssh://git@github.com/",
+				"-c", "url.https://gitlab.com/.insteadOf=git@gitlab.com:",
+				"-c", "url.https://gitlab.com/.insteadOf=ssh://git@gitlab.com/",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := protocolRewrites(urls, tt.toSSH); !slices.Equal(got, tt.expected) {
+				t.Errorf("protocolRewrites() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProtocolRewritesPort(t *testing.T) {
+	urls := []string{"https://git.example.com/team/lib.git", "ssh://git@git.example.com:2222/team/app.git"}
+	tests := []struct {
+		toSSH    bool
+		expected []string
+	}{
+		{true, []string{"-c", "url.ssh://git@git.example.com:2222/.insteadOf=https://git.example.com/"}},
+		{false, []string{
+			"-c", "url.https://git.example.com/.insteadOf=git@git.example.com:",
+			"-c", "url.https://git.example.com/.insteadOf=ssh://git@git.example.com/",
+			"-c", "url.https://git.example.com/.insteadOf=ssh://git@git.example.com:2222/",
+		}},
+	}
+	for _, tt := range tests {
+		if got := protocolRewrites(urls, tt.toSSH); !slices.Equal(got, tt.expected) {
+			t.Errorf("protocolRewrites(toSSH=%v) = %v, want %v", tt.toSSH, got, tt.expected)
+		}
+	}
+}
+
+// allowFileSubmodules lets git clone submodules from local paths, which it
+// refuses by default
+func allowFileSubmodules(t *testing.T) {
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+}
+
+func TestUpdateSubmodules(t *testing.T) {
+	allowFileSubmodules(t)
+	ctx := context.Background()
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	initTestRepo(t, sub)
+
+	tests := []struct {
+		name        string
+		subURL      string
+		expectError string
+	}{
+		{"reachable", "file://" + sub, ""},
+		{"unreachable", "file://" + filepath.Join(root, "missing"), "submodule vendor/sub (file://" + filepath.Join(root, "missing") + "): repository not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := filepath.Join(t.TempDir(), "upstream")
+			initTestRepo(t, upstream)
+			if _, err := gitOutput(ctx, upstream, "submodule", "add", "--quiet", "file://"+sub, "vendor/sub"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := gitOutput(ctx, upstream, "config", "--file", ".gitmodules", "submodule.vendor/sub.url", tt.subURL); err != nil {
+				t.Fatal(err)
+			}
+			commitFile(t, upstream, ".gitmodules", mustRead(t, filepath.Join(upstream, ".gitmodules")))
+			clone := cloneTestRepo(t, upstream)
+
+			err := updateSubmodules(ctx, clone, upstream, Options{})
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Fatalf("updateSubmodules() error = %v, want it to contain %q", err, tt.expectError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("updateSubmodules() error = %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(clone, "vendor", "sub", "doc.go")); err != nil {
+				t.Errorf("submodule not checked out: %v", err)
+			}
+		})
+	}
+}
+
+func mustRead(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestHandleLFS(t *testing.T) {
+	ctx := context.Background()
+	repo := filepath.Join(t.TempDir(), "repo")
+	initTestRepo(t, repo)
+	if usesLFS(ctx, repo) {
+		t.Error("usesLFS() = true for a repository without LFS")
+	}
+	commitFile(t, repo, ".gitattributes", "*.bin filter=lfs diff=lfs merge=lfs -text\n")
+	if !usesLFS(ctx, repo) {
+		t.Error("usesLFS() = false for a repository with LFS")
+	}
+
+	if err := handleLFS(ctx, repo, Options{LFS: lfsSkip}); err != nil {
+		t.Errorf("handleLFS(skip) error = %v", err)
+	}
+	if _, err := exec.LookPath("git-lfs"); err == nil {
+		t.Skip("git-lfs installed")
+	}
+	err := handleLFS(ctx, repo, Options{LFS: lfsPull})
+	if err == nil || !strings.Contains(err.Error(), "git-lfs is not installed") {
+		t.Errorf("handleLFS(pull) error = %v, want git-lfs is not installed", err)
+	}
+}
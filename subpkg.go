@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// maxSuggestions is the number of close matches listed when a requested
+// subpackage does not exist
+const maxSuggestions = 5
+
+// majorVersionDir matches the v2, v3, ... directories that hold major
+// versions of a module inside the same repository
+var majorVersionDir = regexp.MustCompile(`^v[2-9][0-9]*$`)
+
+// hasGoFiles reports whether dir contains any .go files
+func hasGoFiles(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") {
+			return true
+		}
+	}
+	return false
+}
+
+// findSubpackage checks that subdir (a slash-separated path relative to
+// repoDir) is a directory containing Go files. If it is not, it returns up to
+// maxSuggestions packages in the repository with similar paths, starting with
+// the same path under any /vN major version directory.
+func findSubpackage(repoDir, subdir string) (found bool, suggestions []string) {
+	if hasGoFiles(filepath.Join(repoDir, filepath.FromSlash(subdir))) {
+		return true, nil
+	}
+
+	type candidate struct {
+		path     string
+		distance int
+	}
+	var candidates []candidate
+	base := pathBase(subdir)
+	maxDistance := max(2, len(subdir)/3)
+
+	_ = filepath.WalkDir(repoDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if path != repoDir && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			return filepath.SkipDir
+		}
+		if !hasGoFiles(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(repoDir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		// The package may have moved into a major version subtree
+		if major, rest, ok := strings.Cut(rel, "/"); ok && majorVersionDir.MatchString(major) && rest == subdir {
+			candidates = append(candidates, candidate{rel, -1})
+			return nil
+		}
+
+		distance := levenshtein(rel, subdir)
+		if distance <= maxDistance || pathBase(rel) == base {
+			candidates = append(candidates, candidate{rel, distance})
+		}
+		return nil
+	})
+
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		if a.distance != b.distance {
+			return a.distance - b.distance
+		}
+		return strings.Compare(a.path, b.path)
+	})
+	for _, c := range candidates {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		suggestions = append(suggestions, c.path)
+	}
+	return false, suggestions
+}
+
+// pathBase returns the last element of a slash-separated path
+func pathBase(path string) string {
+	if idx := strings.LastIndex(path, "/"); idx != -1 {
+		return path[idx+1:]
+	}
+	return path
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
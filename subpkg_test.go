@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindSubpackage(t *testing.T) {
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{
+		"root.go":                 "package root\n",
+		"some/pkg/pkg.go":         "package pkg\n",
+		"some/pkgs/pkgs.go":       "package pkgs\n",
+		"v2/other/thing/thing.go": "package thing\n",
+		"internal/thing/thing.go": "package thing\n",
+		"docs/README":             "no Go here\n",
+		"testdata/pkg/pkg.go":     "package pkg\n",
+	})
+
+	tests := []struct {
+		name          string
+		subdir        string
+		expectedFound bool
+		expectedSugg  []string
+	}{
+		{
+			name:          "package exists",
+			subdir:        "some/pkg",
+			expectedFound: true,
+		},
+		{
+			name:          "typo",
+			subdir:        "some/pgk",
+			expectedFound: false,
+			expectedSugg:  []string{"some/pkg", "some/pkgs"},
+		},
+		{
+			name:          "moved to major version subtree",
+			subdir:        "other/thing",
+			expectedFound: false,
+			expectedSugg:  []string{"v2/other/thing", "internal/thing"},
+		},
+		{
+			name:          "directory without Go files",
+			subdir:        "docs",
+			expectedFound: false,
+		},
+		{
+			name:          "no close matches",
+			subdir:        "completely/different/path",
+			expectedFound: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, suggestions := findSubpackage(repo, tt.subdir)
+			if found != tt.expectedFound {
+				t.Errorf("found = %v, want %v", found, tt.expectedFound)
+			}
+			if !reflect.DeepEqual(suggestions, tt.expectedSugg) {
+				t.Errorf("suggestions = %v, want %v", suggestions, tt.expectedSugg)
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"some/pkg", "some/pgk", 2},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.expected {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// updateStatus is the outcome of updating an existing clone with -u
+type updateStatus string
+
+const (
+	updateUpdated updateStatus = "updated"
+	updateCurrent updateStatus = "current"
+	updateRefused updateStatus = "refused"
+)
+
+// updateRepo fetches the repository containing dir and fast-forwards the
+// current branch to its upstream. Clones with uncommitted changes, a detached
+// HEAD, no upstream branch or local commits that diverge from the upstream
+// are fetched but left untouched, with updateRefused and the reason.
+func updateRepo(ctx context.Context, dir string, opts Options) (status updateStatus, reason string, err error) {
+	if err := opts.cloner().Fetch(ctx, dir, opts); err != nil {
+		return "", "", fmt.Errorf("could not fetch %s: %w", dir, err)
+	}
+
+	branch, err := gitOutput(ctx, dir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return updateRefused, "HEAD is detached", nil
+	}
+
+	upstream, err := gitOutput(ctx, dir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	if err != nil {
+		return updateRefused, fmt.Sprintf("branch %s does not track a remote branch", branch), nil
+	}
+
+	changes, err := gitOutput(ctx, dir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return "", "", err
+	}
+	if changes != "" {
+		return updateRefused, "working tree has uncommitted changes", nil
+	}
+
+	counts, err := gitOutput(ctx, dir, "rev-list", "--left-right", "--count", "HEAD...@{upstream}")
+	if err != nil {
+		return "", "", err
+	}
+	ahead, behind, err := parseAheadBehind(counts)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch {
+	case behind == 0 && ahead == 0:
+		return updateCurrent, fmt.Sprintf("%s is up to date with %s", branch, upstream), nil
+	case behind == 0:
+		return updateCurrent, fmt.Sprintf("%s is %d commits ahead of %s", branch, ahead, upstream), nil
+	case ahead > 0:
+		return updateRefused, fmt.Sprintf("%s has diverged from %s (%d ahead, %d behind)", branch, upstream, ahead, behind), nil
+	}
+
+	if _, err := gitOutput(ctx, dir, "merge", "--ff-only", "--quiet", "@{upstream}"); err != nil {
+		return "", "", err
+	}
+	return updateUpdated, fmt.Sprintf("fast-forwarded %s by %d commits from %s", branch, behind, upstream), nil
+}
+
+// parseAheadBehind parses the output of
+// "git rev-list --left-right --count HEAD...@{upstream}"
+func parseAheadBehind(counts string) (ahead, behind int, err error) {
+	fields := strings.Fields(counts)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected rev-list output %q", counts)
+	}
+	if ahead, err = strconv.Atoi(fields[0]); err != nil {
+		return 0, 0, err
+	}
+	if behind, err = strconv.Atoi(fields[1]); err != nil {
+		return 0, 0, err
+	}
+	return ahead, behind, nil
+}
+
+// printUpdateSummary prints the -u counts for a batch of fetches, and the
+// reason each refused repository was left alone. It prints nothing if no
+// repository was updated.
+func printUpdateSummary(results []DependencyResult) {
+	counts := make(map[updateStatus]int)
+	for _, result := range results {
+		if result.Update != "" {
+			counts[result.Update]++
+		}
+	}
+	if len(counts) == 0 {
+		return
+	}
+
+	fmt.Printf("Updated: %d | Already current: %d | Refused: %d\n", counts[updateUpdated], counts[updateCurrent], counts[updateRefused])
+	if counts[updateRefused] > 0 {
+		fmt.Println("\nRefused updates:")
+		for _, result := range results {
+			if result.Update == updateRefused {
+				fmt.Printf("  - %s: %s\n", result.ImportPath, result.UpdateReason)
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// commitFile writes a file in the repository in dir and commits it
+func commitFile(t *testing.T, dir, name, contents string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"add", name},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "update " + name},
+	} {
+		if _, err := gitOutput(context.Background(), dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// cloneTestRepo clones the repository in upstream into a new directory and
+// returns its path
+func cloneTestRepo(t *testing.T, upstream string) string {
+	t.Helper()
+	clone := filepath.Join(t.TempDir(), "clone")
+	if _, err := gitOutput(context.Background(), "", "clone", "--quiet", upstream, clone); err != nil {
+		t.Fatal(err)
+	}
+	return clone
+}
+
+func TestUpdateRepo(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name           string
+		setup          func(t *testing.T, upstream, clone string)
+		expectedStatus updateStatus
+	}{
+		{
+			name:           "already current",
+			setup:          func(t *testing.T, upstream, clone string) {},
+			expectedStatus: updateCurrent,
+		},
+		{
+			name: "fast-forward",
+			setup: func(t *testing.T, upstream, clone string) {
+				commitFile(t, upstream, "new.go", "package repo\n")
+			},
+			expectedStatus: updateUpdated,
+		},
+		{
+			name: "local commits only",
+			setup: func(t *testing.T, upstream, clone string) {
+				commitFile(t, clone, "local.go", "package repo\n")
+			},
+			expectedStatus: updateCurrent,
+		},
+		{
+			name: "dirty work tree",
+			setup: func(t *testing.T, upstream, clone string) {
+				commitFile(t, upstream, "new.go", "package repo\n")
+				if err := os.WriteFile(filepath.Join(clone, "doc.go"), []byte("package changed\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			expectedStatus: updateRefused,
+		},
+		{
+			name: "diverged",
+			setup: func(t *testing.T, upstream, clone string) {
+				commitFile(t, upstream, "new.go", "package repo\n")
+				commitFile(t, clone, "local.go", "package repo\n")
+			},
+			expectedStatus: updateRefused,
+		},
+		{
+			name: "detached HEAD",
+			setup: func(t *testing.T, upstream, clone string) {
+				if _, err := gitOutput(ctx, clone, "checkout", "--quiet", "--detach"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			expectedStatus: updateRefused,
+		},
+		{
+			name: "no upstream branch",
+			setup: func(t *testing.T, upstream, clone string) {
+				if _, err := gitOutput(ctx, clone, "checkout", "--quiet", "-b", "local"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			expectedStatus: updateRefused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := filepath.Join(t.TempDir(), "upstream")
+			initTestRepo(t, upstream)
+			clone := cloneTestRepo(t, upstream)
+			tt.setup(t, upstream, clone)
+
+			status, reason, err := updateRepo(ctx, clone, Options{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if status != tt.expectedStatus {
+				t.Errorf("status = %q (%s), want %q", status, reason, tt.expectedStatus)
+			}
+			if reason == "" {
+				t.Error("expected a reason")
+			}
+		})
+	}
+}
+
+func TestParseAheadBehind(t *testing.T) {
+	ahead, behind, err := parseAheadBehind("3\t5")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ahead != 3 || behind != 5 {
+		t.Errorf("parseAheadBehind() = %d, %d, want 3, 5", ahead, behind)
+	}
+	if _, _, err := parseAheadBehind("garbage"); err == nil {
+		t.Error("expected error but got none")
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// The values accepted by --layout
+const (
+	layoutGOPATH    = "gopath"    // one checkout per import path
+	layoutVersioned = "versioned" // path@version checkouts next to the canonical clone
+)
+
+// pseudoVersionRev matches the commit hash at the end of a pseudo-version
+// such as v0.0.0-20231010123456-abcdef123456
+var pseudoVersionRev = regexp.MustCompile(`(?:^v\d+\.\d+\.\d+-|\.)\d{14}-([0-9a-f]{12})$`)
+
+// escapeModulePath escapes a module path or version the way the module
+// cache does, replacing every uppercase letter with "!" followed by the
+// lowercase letter, so paths stay unique on case-insensitive file systems.
+func escapeModulePath(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// versionRef returns the git revision for a module version: the commit hash
+// of a pseudo-version, or otherwise the tag, which is prefixed with the
+// module's directory in the repository for modules that don't live at the
+// repository root.
+func versionRef(root, modulePath, version string) string {
+	version = strings.TrimSuffix(version, "+incompatible")
+	if m := pseudoVersionRev.FindStringSubmatch(version); m != nil {
+		return m[1]
+	}
+	if dir := moduleDir(root, modulePath); dir != "" {
+		return dir + "/" + version
+	}
+	return version
+}
+
+// moduleDir returns the directory of the module modulePath within the
+// repository rooted at root, or "" for the root module
+func moduleDir(root, modulePath string) string {
+	return strings.Trim(strings.TrimPrefix(stripMajorVersion(modulePath), root), "/")
+}
+
+// versionedPath returns where --layout=versioned puts version of the
+// repository rooted at root: $GOPATH/src/<root>@<version>, escaped like the
+// module cache, next to the canonical clone rather than inside it. A nested
+// module's tag, such as sdk/v1.20.0, may be a different commit from the root
+// module's, so it gets its own directory, <root>@sdk@v1.20.0; dir is the
+// nested module's directory, or "" for the root module's tags and commits.
+func versionedPath(gopath, root, dir, version string) string {
+	name := escapeModulePath(root) + "@"
+	if dir != "" {
+		name += escapeModulePath(strings.ReplaceAll(dir, "/", "@")) + "@"
+	}
+	name += escapeModulePath(version)
+	return filepath.Join(gopath, "src", filepath.FromSlash(name))
+}
+
+// versionCandidate is a git revision that may hold a module version, and the
+// directory of the module whose tag it is, "" unless it is a nested module's
+type versionCandidate struct {
+	Ref string
+	Dir string
+}
+
+// addVersionedCheckout checks out a module version as a git worktree of the
+// canonical clone in repoDir, so every version shares the clone's object
+// storage. The worktree holds the whole repository, so a nested module's
+// files are in its directory within it. It returns skipped=true if that
+// version is already checked out. If the clone lacks the version's tag, it is
+// fetched with opts' settings.
+func addVersionedCheckout(ctx context.Context, config *Config, repoDir string, opts Options) (skipped bool, err error) {
+	srcDir := filepath.Join(config.GOPATH, "src")
+	rel, err := filepath.Rel(srcDir, repoDir)
+	if err != nil {
+		return false, err
+	}
+	root := filepath.ToSlash(rel)
+
+	// The import path may name a package of the root module rather than a
+	// nested module, so fall back to the unprefixed tag, a version of the
+	// root module
+	candidates := []versionCandidate{{Ref: versionRef(root, config.ImportPath, config.Version)}}
+	if dir := moduleDir(root, config.ImportPath); dir != "" && strings.HasPrefix(candidates[0].Ref, dir+"/") {
+		candidates[0].Dir = dir
+	}
+	if plain := strings.TrimSuffix(config.Version, "+incompatible"); candidates[0].Ref != plain {
+		candidates = append(candidates, versionCandidate{Ref: plain})
+	}
+	resolve := func() (candidate versionCandidate, commit string, ok bool) {
+		for _, candidate := range candidates {
+			if commit, err := gitOutput(ctx, repoDir, "rev-parse", "--verify", "--quiet", candidate.Ref+"^{commit}"); err == nil {
+				return candidate, commit, true
+			}
+		}
+		return versionCandidate{}, "", false
+	}
+
+	candidate, commit, ok := resolve()
+	if !ok {
+		// The canonical clone may predate the tag we need
+		env := remoteEnv(ctx, nil, repoDir, repoRemoteURL(ctx, repoDir), opts)
+		if _, err := gitOutputEnv(ctx, repoDir, env, "fetch", "--quiet", "--tags", "origin"); err != nil {
+			return false, err
+		}
+		candidate, commit, ok = resolve()
+		if !ok {
+			return false, fmt.Errorf("could not find revision %s for %s@%s in %s", candidates[0].Ref, config.ImportPath, config.Version, repoDir)
+		}
+	}
+
+	target := versionedPath(config.GOPATH, root, candidate.Dir, config.Version)
+	if _, err := os.Stat(target); err == nil {
+		fmt.Printf("Version %s already exists at %s, skipping\n", config.Version, target)
+		return true, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return false, err
+	}
+	fmt.Printf("git worktree add --detach %s %s\n", target, candidate.Ref)
+	if _, err := gitOutput(ctx, repoDir, "worktree", "add", "--quiet", "--detach", target, commit); err != nil {
+		return false, err
+	}
+	return false, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestEscapeModulePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"github.com/user/repo", "github.com/user/repo"},
+		{"github.com/BurntSushi/toml", "github.com/!burnt!sushi/toml"},
+		{"v1.0.0-RC1", "v1.0.0-!r!c1"},
+	}
+
+	for _, tt := range tests {
+		if got := escapeModulePath(tt.path); got != tt.expected {
+			t.Errorf("escapeModulePath(%q) = %q, want %q", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestVersionRef(t *testing.T) {
+	tests := []struct {
+		name       string
+		root       string
+		modulePath string
+		version    string
+		expected   string
+	}{
+		{
+			name:       "tag at repository root",
+			root:       "github.com/user/repo",
+			modulePath: "github.com/user/repo",
+			version:    "v1.2.3",
+			expected:   "v1.2.3",
+		},
+		{
+			name:       "major version module",
+			root:       "github.com/user/repo",
+			modulePath: "github.com/user/repo/v2",
+			version:    "v2.0.1",
+			expected:   "v2.0.1",
+		},
+		{
+			name:       "nested module",
+			root:       "go.opentelemetry.io/otel",
+			modulePath: "go.opentelemetry.io/otel/sdk/metric",
+			version:    "v1.20.0",
+			expected:   "sdk/metric/v1.20.0",
+		},
+		{
+			name:       "incompatible",
+			root:       "github.com/user/repo",
+			modulePath: "github.com/user/repo",
+			version:    "v3.0.0+incompatible",
+			expected:   "v3.0.0",
+		},
+		{
+			name:       "pseudo-version",
+			root:       "github.com/user/repo",
+			modulePath: "github.com/user/repo",
+			version:    "v0.0.0-20231010123456-abcdef123456",
+			expected:   "abcdef123456",
+		},
+		{
+			name:       "pseudo-version after a tag",
+			root:       "github.com/user/repo",
+			modulePath: "github.com/user/repo",
+			version:    "v1.2.4-0.20231010123456-abcdef123456",
+			expected:   "abcdef123456",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := versionRef(tt.root, tt.modulePath, tt.version); got != tt.expected {
+				t.Errorf("versionRef() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVersionedPath(t *testing.T) {
+	tests := []struct {
+		root, dir, version string
+		expected           string
+	}{
+		{"github.com/BurntSushi/toml", "", "v1.3.2", "/home/user/go/src/github.com/!burnt!sushi/toml@v1.3.2"},
+		{"go.opentelemetry.io/otel", "sdk", "v1.20.0", "/home/user/go/src/go.opentelemetry.io/otel@sdk@v1.20.0"},
+		{"github.com/aws/sdk", "service/S3", "v1.0.0", "/home/user/go/src/github.com/aws/sdk@service@!s3@v1.0.0"},
+	}
+	for _, tt := range tests {
+		if got := versionedPath("/home/user/go", tt.root, tt.dir, tt.version); got != filepath.FromSlash(tt.expected) {
+			t.Errorf("versionedPath(%q, %q, %q) = %q, want %q", tt.root, tt.dir, tt.version, got, tt.expected)
+		}
+	}
+}
+
+// initTestRepo creates a git repository in dir with a single commit, tagged
+// with each of tags. It skips the test if git is not installed.
+func initTestRepo(t *testing.T, dir string, tags ...string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "doc.go"), []byte("package repo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	commands := [][]string{
+		{"init", "--quiet", "--initial-branch=main"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "initial"},
+	}
+	for _, tag := range tags {
+		commands = append(commands, []string{"tag", tag})
+	}
+	for _, args := range commands {
+		if _, err := gitOutput(context.Background(), dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAddVersionedCheckout(t *testing.T) {
+	gopath := t.TempDir()
+	repoDir := filepath.Join(gopath, "src", "github.com", "User", "repo")
+	initTestRepo(t, repoDir, "v1.0.0")
+
+	config := &Config{
+		GOPATH:     gopath,
+		ImportPath: "github.com/User/repo",
+		Version:    "v1.0.0",
+	}
+
+	skipped, err := addVersionedCheckout(context.Background(), config, repoDir, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if skipped {
+		t.Error("expected the first checkout not to be skipped")
+	}
+	target := filepath.Join(gopath, "src", "github.com", "!user", "repo@v1.0.0")
+	if _, err := os.Stat(filepath.Join(target, "doc.go")); err != nil {
+		t.Fatalf("expected a checkout at %s: %v", target, err)
+	}
+
+	skipped, err = addVersionedCheckout(context.Background(), config, repoDir, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !skipped {
+		t.Error("expected the second checkout to be skipped")
+	}
+
+	config.Version = "v9.9.9"
+	if _, err := addVersionedCheckout(context.Background(), config, repoDir, Options{}); err == nil {
+		t.Error("expected an error for a missing version")
+	}
+}
+
+func TestAddVersionedCheckoutNestedModules(t *testing.T) {
+	gopath := t.TempDir()
+	repoDir := filepath.Join(gopath, "src", "go.opentelemetry.io", "otel")
+	initTestRepo(t, repoDir, "v1.20.0")
+	// Each nested module's tag is on its own commit
+	for _, module := range []string{"sdk", "trace"} {
+		commitFile(t, repoDir, module+".txt", module)
+		if _, err := gitOutput(context.Background(), repoDir, "tag", module+"/v1.20.0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		importPath    string
+		expectTarget  string
+		expectSkipped bool
+		hasFile       string // committed at or before the module's tag
+		lacksFile     string // committed after it
+	}{
+		{"go.opentelemetry.io/otel", "otel@v1.20.0", false, "doc.go", "sdk.txt"},
+		{"go.opentelemetry.io/otel/sdk", "otel@sdk@v1.20.0", false, "sdk.txt", "trace.txt"},
+		{"go.opentelemetry.io/otel/trace", "otel@trace@v1.20.0", false, "trace.txt", ""},
+		// A package of the root module, which has no tag of its own
+		{"go.opentelemetry.io/otel/attribute", "otel@v1.20.0", true, "doc.go", "sdk.txt"},
+	}
+	for _, tt := range tests {
+		config := &Config{GOPATH: gopath, ImportPath: tt.importPath, Version: "v1.20.0"}
+		skipped, err := addVersionedCheckout(context.Background(), config, repoDir, Options{})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.importPath, err)
+		}
+		if skipped != tt.expectSkipped {
+			t.Errorf("%s: skipped = %t, want %t", tt.importPath, skipped, tt.expectSkipped)
+		}
+		target := filepath.Join(gopath, "src", "go.opentelemetry.io", filepath.FromSlash(tt.expectTarget))
+		if _, err := os.Stat(filepath.Join(target, tt.hasFile)); err != nil {
+			t.Errorf("%s: expected %s in the checkout at %s: %v", tt.importPath, tt.hasFile, target, err)
+		}
+		if tt.lacksFile != "" {
+			if _, err := os.Stat(filepath.Join(target, tt.lacksFile)); err == nil {
+				t.Errorf("%s: %s has %s, which is not at the module's tag", tt.importPath, target, tt.lacksFile)
+			}
+		}
+	}
+	// Worktrees must not show up as untracked files in the canonical clone
+	if out, err := gitOutput(context.Background(), repoDir, "status", "--porcelain"); err != nil || out != "" {
+		t.Errorf("git status in %s = %q, %v; want a clean work tree", repoDir, out, err)
+	}
+}